@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// ExperimentBucket is one weighted variant in an Experiment. Weight
+// controls what fraction of assignments land here (higher is more
+// likely; 0 counts as 1), mirroring CanaryTarget's Weight semantics.
+type ExperimentBucket struct {
+	Name   string
+	Weight int
+}
+
+// Experiment is a named A/B (or A/B/n) test: a set of weighted buckets
+// a client is deterministically assigned to by AssignBucket.
+type Experiment struct {
+	Name    string
+	Buckets []ExperimentBucket
+}
+
+// ErrNoExperimentBucket is returned by AssignBucket when experiment
+// has no buckets to choose from.
+var ErrNoExperimentBucket = errors.New("middleware: experiment has no buckets to assign")
+
+// experimentCookieName returns the cookie AssignBucket uses to persist
+// a client's assignment for experimentName.
+func experimentCookieName(experimentName string) string {
+	return "caddy_experiment_" + experimentName
+}
+
+// AssignBucket returns the bucket name the current client is assigned
+// to within experiment. If the experiment's cookie already names a
+// bucket still present, that assignment is kept, the same sticky
+// pattern SelectCanary uses. Otherwise the client is assigned
+// deterministically by hashing seed (typically a stable per-visitor
+// value such as a session ID or c.ClientIP) against the weighted
+// buckets — the same seed always maps to the same bucket, so
+// assignment stays consistent across server restarts and multiple
+// instances behind a load balancer without any shared state — and the
+// cookie is set so the choice sticks for subsequent requests too. The
+// bucket name isn't otherwise exposed; a directive assigns it to
+// c.Vars (e.g. c.Vars["bucket"] = name) to use it as a placeholder for
+// rewrite, headers, proxy upstream selection, and templates.
+func (c Context) AssignBucket(experiment Experiment, seed string) (string, error) {
+	if len(experiment.Buckets) == 0 {
+		return "", ErrNoExperimentBucket
+	}
+
+	cookieName := experimentCookieName(experiment.Name)
+	if sticky := c.Cookie(cookieName); sticky != "" && experimentHasBucket(experiment.Buckets, sticky) {
+		return sticky, nil
+	}
+
+	choice := deterministicBucketChoice(experiment.Buckets, seed)
+	c.SetCookie(cookieName, choice)
+	return choice, nil
+}
+
+// experimentHasBucket reports whether buckets contains a bucket named
+// name.
+func experimentHasBucket(buckets []ExperimentBucket, name string) bool {
+	for _, b := range buckets {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deterministicBucketChoice picks the bucket seed falls into, weighted
+// by Weight, the same way weightedCanaryChoice does but hashing seed
+// instead of calling math/rand, so the same seed always yields the
+// same bucket.
+func deterministicBucketChoice(buckets []ExperimentBucket, seed string) string {
+	total := 0
+	for _, b := range buckets {
+		total += experimentWeight(b)
+	}
+
+	sum := sha256.Sum256([]byte(seed))
+	pick := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+
+	for _, b := range buckets {
+		pick -= experimentWeight(b)
+		if pick < 0 {
+			return b.Name
+		}
+	}
+	return buckets[len(buckets)-1].Name
+}
+
+// experimentWeight returns b.Weight, or 1 if it's zero.
+func experimentWeight(b ExperimentBucket) int {
+	if b.Weight == 0 {
+		return 1
+	}
+	return b.Weight
+}
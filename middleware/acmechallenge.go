@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// wellKnownACMEChallengePrefix is the fixed path ACME HTTP-01
+// validation requests use, per RFC 8555.
+const wellKnownACMEChallengePrefix = "/.well-known/acme-challenge/"
+
+// ErrACMEChallengeInvalidToken is returned by ServeACMEChallenge when
+// the request path's token isn't a single safe path segment.
+var ErrACMEChallengeInvalidToken = errors.New("middleware: invalid ACME challenge token")
+
+// IsACMEChallengeRequest reports whether the current request path is
+// under /.well-known/acme-challenge/, so a handler can check this
+// before its other routing rather than treating the challenge path as
+// an internal special case baked into the routing itself.
+func (c Context) IsACMEChallengeRequest() bool {
+	return strings.HasPrefix(c.Req.URL.Path, wellKnownACMEChallengePrefix)
+}
+
+// ServeACMEChallenge serves the current request's ACME HTTP-01
+// challenge token from tokenDir, the directory an external ACME
+// client (running independently of this server) drops its tokens
+// into. Call it only after IsACMEChallengeRequest reports true. It
+// returns ErrACMEChallengeInvalidToken if the request path's token
+// isn't a plain filename, so a directory an ACME client controls
+// can't be used to read arbitrary files outside it.
+func (c Context) ServeACMEChallenge(tokenDir string) error {
+	token := strings.TrimPrefix(c.Req.URL.Path, wellKnownACMEChallengePrefix)
+	if token == "" || token == "." || token == ".." || strings.ContainsAny(token, "/\\") {
+		return ErrACMEChallengeInvalidToken
+	}
+
+	body, err := ioutil.ReadFile(filepath.Join(tokenDir, token))
+	if err != nil {
+		return err
+	}
+
+	c.ResponseWriter.Header().Set("Content-Type", "text/plain")
+	_, err = c.ResponseWriter.Write(body)
+	return err
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AltSvcOptions configures SetAltSvc.
+type AltSvcOptions struct {
+	// Values lists each alternative service to advertise, e.g.
+	// `h3=":443"` or `h2=":443"`.
+	Values []string
+
+	// MaxAge is included as every value's ma= parameter.
+	MaxAge time.Duration
+}
+
+// SetAltSvc sets the response's Alt-Svc header from opts, so a
+// directive advertises HTTP/3 (or another upgrade path) from one
+// place instead of every handler setting its own header. Passing
+// AltSvcOptions{} (no Values) clears any existing Alt-Svc header
+// instead of writing an empty one, for a graceful-shutdown path that
+// stops advertising an upgrade path the server is about to stop
+// serving. Tracking per-connection protocol usage as a metric needs
+// this package's metrics pipeline this tree doesn't have (see
+// RunHookCommand's doc comment for the same boundary); HTTPVersion
+// exposes the current request's protocol (and the "protocol"
+// placeholder) for a directive with its own metrics or logging to
+// consume.
+func (c Context) SetAltSvc(opts AltSvcOptions) {
+	if len(opts.Values) == 0 {
+		c.ResponseWriter.Header().Del("Alt-Svc")
+		return
+	}
+
+	maxAge := int(opts.MaxAge.Seconds())
+	parts := make([]string, len(opts.Values))
+	for i, v := range opts.Values {
+		parts[i] = fmt.Sprintf("%s; ma=%d", v, maxAge)
+	}
+	c.ResponseWriter.Header().Set("Alt-Svc", strings.Join(parts, ", "))
+}
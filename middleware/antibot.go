@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChallengeCookie is the cookie IssueChallenge asks the client to set
+// via JavaScript, and ChallengePassed checks for, to tell a browser
+// (which executes JavaScript) apart from a plain HTTP scraper (which
+// usually doesn't).
+const ChallengeCookie = "caddy_challenge"
+
+// MissingHeaders reports whether the current request lacks any one of
+// names, entirely or as an empty value, for flagging a request that's
+// missing headers a real browser always sends (Accept, Accept-
+// Language, ...) as likely automated.
+func (c Context) MissingHeaders(names ...string) bool {
+	for _, name := range names {
+		if c.Header(name) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Tarpit sleeps for delay before returning, for a directive that wants
+// to slow down a request it suspects is an automated scanner rather
+// than rejecting it outright, making high-volume scraping too slow to
+// be worthwhile without breaking a legitimate but oddly-behaved client
+// outright.
+func (c Context) Tarpit(delay time.Duration) string {
+	time.Sleep(delay)
+	return ""
+}
+
+// IssueChallenge returns a minimal HTML page that sets ChallengeCookie
+// from JavaScript before reloading the page. Deliberately, it does
+// not set the cookie via a Set-Cookie header itself: a plain HTTP
+// client (curl, most scrapers) would pick that up without running any
+// JavaScript, defeating the point. Only a client that actually
+// executes the returned page's script leaves proof of that in
+// ChallengeCookie on its next request, letting ChallengePassed tell a
+// real browser apart from one that ignores <script> tags.
+func (c Context) IssueChallenge() (string, error) {
+	token, err := c.RandomString(16, "")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		`<!DOCTYPE html><html><head><script>document.cookie=%q;location.reload();</script></head><body></body></html>`,
+		ChallengeCookie+"="+token+"; path=/",
+	), nil
+}
+
+// ChallengePassed reports whether the current request carries
+// ChallengeCookie, as set by a prior IssueChallenge response's
+// JavaScript having actually run.
+func (c Context) ChallengePassed() bool {
+	return c.Cookie(ChallengeCookie) != ""
+}
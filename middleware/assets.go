@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// AssetURL returns name with a "?v=" query parameter appended holding
+// a short hash of the file's current content, so a template can link
+// to it as {{.AssetURL "css/site.css"}} and safely pair it with a
+// far-future SetExpires: the URL changes whenever the file's content
+// does, busting any cache keyed by URL.
+func (c Context) AssetURL(name string) (string, error) {
+	content, err := c.readFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])[:8]
+
+	sep := "?"
+	if strings.Contains(name, "?") {
+		sep = "&"
+	}
+	return name + sep + "v=" + hash, nil
+}
+
+// SubresourceIntegrity returns the value of a "sha384"-prefixed
+// Subresource Integrity hash for name's current content, suitable for
+// a <script>/<link> integrity="..." attribute, e.g.
+// {{.SubresourceIntegrity "js/vendor.js"}}.
+func (c Context) SubresourceIntegrity(name string) (string, error) {
+	content, err := c.readFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha512.Sum384([]byte(content))
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditOutcome is the result of an authentication attempt recorded by
+// AuditEvent.
+type AuditOutcome string
+
+const (
+	// AuditSuccess marks an authentication attempt that succeeded.
+	AuditSuccess AuditOutcome = "success"
+	// AuditFailure marks an authentication attempt that failed.
+	AuditFailure AuditOutcome = "failure"
+)
+
+// AuditEvent is a structured record of an authentication attempt
+// (basicauth, JWT, or any other scheme a directive layers on top of
+// Context), for compliance environments that need a record of who
+// attempted what, when, and with what outcome. This package has no
+// logging sink of its own (see RunHookCommand), so a directive builds
+// one via NewAuditEvent and writes MarshalLogLine's result to whatever
+// log target it already has.
+type AuditEvent struct {
+	Time     time.Time    `json:"time"`
+	Subject  string       `json:"subject"`
+	Path     string       `json:"path"`
+	Outcome  AuditOutcome `json:"outcome"`
+	SourceIP string       `json:"source_ip"`
+}
+
+// NewAuditEvent builds an AuditEvent for the current request: subject
+// identifies who authenticated (e.g. a username or JWT "sub" claim),
+// and outcome records whether the attempt succeeded.
+func (c Context) NewAuditEvent(subject string, outcome AuditOutcome) AuditEvent {
+	return AuditEvent{
+		Time:     c.Now(),
+		Subject:  subject,
+		Path:     c.Req.URL.Path,
+		Outcome:  outcome,
+		SourceIP: c.ClientIP(),
+	}
+}
+
+// MarshalLogLine returns e as a single-line JSON object, for a
+// directive to append to an audit log file.
+func (e AuditEvent) MarshalLogLine() (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
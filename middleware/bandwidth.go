@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ThrottledWriter wraps an http.ResponseWriter so writes past
+// freeBytes are shaped to at most rateBytesPerSec bytes per second,
+// for a `throttle` directive protecting the uplink from one large
+// download saturating it (e.g. full speed for the first 1MB, then
+// 512k/s). The first freeBytes written go through unthrottled;
+// bytes past that pause just long enough to keep the overall
+// throttled rate at or below rateBytesPerSec. It wraps a single
+// response, so the limit it enforces is per connection.
+type ThrottledWriter struct {
+	http.ResponseWriter
+	freeBytes       int64
+	rateBytesPerSec float64
+	start           time.Time
+	sleep           func(time.Duration)
+	written         int64
+	throttled       int64
+}
+
+// NewThrottledWriter wraps w, allowing freeBytes through at full
+// speed before throttling to rateBytesPerSec, timed from start
+// (typically time.Now() when the response begins).
+func NewThrottledWriter(w http.ResponseWriter, freeBytes int64, rateBytesPerSec float64, start time.Time) *ThrottledWriter {
+	return &ThrottledWriter{ResponseWriter: w, freeBytes: freeBytes, rateBytesPerSec: rateBytesPerSec, start: start, sleep: time.Sleep}
+}
+
+// Write writes p to the underlying writer, first sleeping as long as
+// needed so any bytes in p past freeBytes don't push the throttled
+// portion of the response above rateBytesPerSec on average.
+func (w *ThrottledWriter) Write(p []byte) (int, error) {
+	remainingFree := w.freeBytes - w.written
+	if remainingFree < 0 {
+		remainingFree = 0
+	}
+	if over := int64(len(p)) - remainingFree; over > 0 && w.rateBytesPerSec > 0 {
+		w.throttled += over
+		expected := time.Duration(float64(w.throttled) / w.rateBytesPerSec * float64(time.Second))
+		if elapsed := time.Since(w.start); expected > elapsed {
+			w.sleep(expected - elapsed)
+		}
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// BandwidthLimiter is a byte-per-second token bucket keyed by an
+// arbitrary string (typically a client IP), for a `throttle`
+// directive capping bandwidth per client rather than per connection:
+// several concurrent downloads to the same client share one budget
+// instead of each getting its own. The zero value is ready to use.
+type BandwidthLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+// Reserve consumes n bytes worth of tokens from key's bucket (which
+// refills at rate bytes/sec up to burst bytes) and returns how long
+// the caller should wait before sending those bytes, 0 if the bucket
+// already covers them without waiting.
+func (l *BandwidthLimiter) Reserve(key string, n int, rate float64, burst int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = make(map[string]*rateBucket)
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &rateBucket{tokens: float64(burst), lastRefill: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.lastRefill).Seconds()
+	if elapsed > 0 {
+		bucket.tokens += elapsed * rate
+		if bucket.tokens > float64(burst) {
+			bucket.tokens = float64(burst)
+		}
+		bucket.lastRefill = now
+	}
+
+	bucket.tokens -= float64(n)
+	if bucket.tokens >= 0 || rate <= 0 {
+		if bucket.tokens < 0 {
+			bucket.tokens = 0
+		}
+		return 0
+	}
+	wait := time.Duration(-bucket.tokens / rate * float64(time.Second))
+	bucket.tokens = 0
+	return wait
+}
+
+// ThrottledIPWriter wraps an http.ResponseWriter, shaping its writes
+// through a shared BandwidthLimiter keyed by a client IP, for
+// per-client (rather than per-connection) bandwidth throttling.
+type ThrottledIPWriter struct {
+	http.ResponseWriter
+	limiter *BandwidthLimiter
+	key     string
+	rate    float64
+	burst   int
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+// NewThrottledIPWriter wraps c.ResponseWriter so its writes draw from
+// limiter's bucket for the current request's client IP, shaped to
+// rateBytesPerSec with burstBytes allowed through immediately.
+func (c Context) NewThrottledIPWriter(limiter *BandwidthLimiter, rateBytesPerSec float64, burstBytes int) *ThrottledIPWriter {
+	return &ThrottledIPWriter{
+		ResponseWriter: c.ResponseWriter,
+		limiter:        limiter,
+		key:            c.ClientIP(),
+		rate:           rateBytesPerSec,
+		burst:          burstBytes,
+		now:            time.Now,
+		sleep:          time.Sleep,
+	}
+}
+
+// Write reserves len(p) bytes from the client's shared bucket,
+// sleeping first if the bucket says to, then writes p to the
+// underlying writer.
+func (w *ThrottledIPWriter) Write(p []byte) (int, error) {
+	if wait := w.limiter.Reserve(w.key, len(p), w.rate, w.burst, w.now()); wait > 0 {
+		w.sleep(wait)
+	}
+	return w.ResponseWriter.Write(p)
+}
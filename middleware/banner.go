@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// bannerSweepInterval bounds how often RecordFailure walks the whole
+// entries map looking for stale keys, so the sweep itself doesn't turn
+// every call into an O(map size) operation.
+const bannerSweepInterval = time.Minute
+
+// Banner tracks authentication failures (or any other event, such as
+// a 4xx burst) per key, typically a client IP, and temporarily bans a
+// key once it accumulates threshold events within a window, for a
+// fail2ban-style directive. Entries whose ban has expired and that
+// have seen no recent failures are pruned automatically, so a hostile
+// client rotating source IPs to evade a ban can't turn this map into a
+// memory-exhaustion vector. The zero value is ready to use.
+type Banner struct {
+	mu      sync.Mutex
+	entries map[string]*banEntry
+	sweep   sweepGate
+}
+
+// banEntry tracks one key's recent failure timestamps, its last
+// failure time, and any active ban.
+type banEntry struct {
+	failures    []time.Time
+	lastSeen    time.Time
+	bannedUntil time.Time
+}
+
+// RecordFailure records a failure for key at now, first dropping any
+// recorded failure older than window, and reports whether this
+// failure pushed key's count to threshold or more, triggering a ban
+// until now+banDuration. A directive calls this once per observed
+// failure with the same threshold/window/banDuration it was
+// configured with.
+func (b *Banner) RecordFailure(key string, threshold int, window, banDuration time.Duration, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.entries == nil {
+		b.entries = make(map[string]*banEntry)
+	}
+	entry, ok := b.entries[key]
+	if !ok {
+		entry = &banEntry{}
+		b.entries[key] = entry
+	}
+
+	cutoff := now.Add(-window)
+	kept := entry.failures[:0]
+	for _, t := range entry.failures {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	entry.failures = append(kept, now)
+	entry.lastSeen = now
+
+	banned := false
+	if len(entry.failures) >= threshold {
+		entry.bannedUntil = now.Add(banDuration)
+		entry.failures = nil
+		banned = true
+	}
+
+	if b.sweep.due(now, bannerSweepInterval) {
+		cutoff := now.Add(-window)
+		for k, e := range b.entries {
+			if now.After(e.bannedUntil) && e.lastSeen.Before(cutoff) {
+				delete(b.entries, k)
+			}
+		}
+	}
+
+	return banned
+}
+
+// Banned reports whether key is currently under an active ban at now.
+func (b *Banner) Banned(key string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok {
+		return false
+	}
+	return now.Before(entry.bannedUntil)
+}
+
+// RecordAuthFailure records an authentication failure for the current
+// request's ClientIP against banner, reporting whether it triggered a
+// ban. See Banner.RecordFailure for threshold/window/banDuration.
+func (c Context) RecordAuthFailure(banner *Banner, threshold int, window, banDuration time.Duration) bool {
+	return banner.RecordFailure(c.ClientIP(), threshold, window, banDuration, c.Now())
+}
+
+// IPAllowedWithBanner behaves like IPAllowed, additionally denying the
+// request if banner currently bans its ClientIP, so a directive
+// tracking authentication failures via RecordAuthFailure shares its
+// ban decision with the ipfilter layer instead of keeping a second,
+// separate deny list.
+func (c Context) IPAllowedWithBanner(filter IPFilter, banner *Banner) bool {
+	if banner.Banned(c.ClientIP(), c.Now()) {
+		return false
+	}
+	return c.IPAllowed(filter)
+}
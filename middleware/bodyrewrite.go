@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BodyReplacement is one literal or regex substitution RewriteBody
+// applies to a response body. Regex uses compiledRegexp (cached the
+// same as elsewhere in this package); a pattern that fails to compile
+// is skipped rather than aborting the remaining replacements.
+type BodyReplacement struct {
+	Pattern     string
+	Regex       bool
+	Replacement string
+}
+
+// RewriteBody applies replacements to body in order and returns the
+// result, for a `replace` directive rewriting a proxied response
+// in-flight (e.g. fixing absolute URLs a legacy upstream hardcodes to
+// its internal hostname). Replacement is placeholder-aware: it's
+// resolved once per rule via Expand/CompileFormat before being
+// substituted in, the same way any other placeholder-bearing string
+// in this package is expanded. Actually reading a live proxied
+// response body to rewrite needs the reverse-proxy layer this tree
+// doesn't have; a directive with one would buffer the upstream body,
+// call this on it, then call SetBodyContentLength before writing the
+// result to c.ResponseWriter.
+func (c Context) RewriteBody(body string, replacements []BodyReplacement) string {
+	for _, r := range replacements {
+		replacement := c.Expand(CompileFormat(r.Replacement))
+		if r.Regex {
+			re, err := compiledRegexp(r.Pattern)
+			if err != nil {
+				continue
+			}
+			body = re.ReplaceAllString(body, replacement)
+		} else {
+			body = strings.ReplaceAll(body, r.Pattern, replacement)
+		}
+	}
+	return body
+}
+
+// SetBodyContentLength sets the response's Content-Length header to
+// len(body), for a directive to call after RewriteBody changes a
+// response's size.
+func (c Context) SetBodyContentLength(body string) {
+	c.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(body)))
+}
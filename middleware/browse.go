@@ -0,0 +1,522 @@
+package middleware
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// browseSortCookie and browseOrderCookie are the cookies ListDirSorted
+// persists a visitor's chosen ordering to, so it carries over to the
+// next directory they browse.
+const (
+	browseSortCookie  = "browse_sort"
+	browseOrderCookie = "browse_order"
+)
+
+// SortPreference returns the visitor's directory-listing sort field and
+// order, read from the sort/order query string parameters if present,
+// falling back to their previously saved browse_sort/browse_order
+// cookies, and finally to "name"/"asc".
+func (c Context) SortPreference() (sortBy, order string) {
+	sortBy = c.Query("sort")
+	if sortBy == "" {
+		sortBy = c.Cookie(browseSortCookie)
+	}
+	if sortBy == "" {
+		sortBy = "name"
+	}
+
+	order = c.Query("order")
+	if order == "" {
+		order = c.Cookie(browseOrderCookie)
+	}
+	if order == "" {
+		order = "asc"
+	}
+
+	return sortBy, order
+}
+
+// SaveSortPreference persists sortBy and order as cookies, so that
+// subsequent directory views default to the same ordering.
+func (c Context) SaveSortPreference(sortBy, order string) {
+	c.SetCookie(browseSortCookie, sortBy)
+	c.SetCookie(browseOrderCookie, order)
+}
+
+// FileEntry is a machine-readable description of one entry returned by
+// ListDir, meant to be serialized (e.g. via ToJSON) for a directory
+// listing consumed by something other than an HTML template, such as
+// an SPA file manager.
+type FileEntry struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	IsDir       bool      `json:"isDir"`
+	ModTime     time.Time `json:"modTime"`
+	Mode        string    `json:"mode"`
+	URL         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+}
+
+// ListDir returns the entries of the directory named name, in the same
+// order as Files, augmented with the URL each entry would be reached
+// at from the current request path. Entries whose name matches one of
+// c.HidePatterns (shell globs, e.g. ".git" or "*.tmp") are omitted;
+// this only affects the listing, not whether the file server itself
+// will still serve a direct request for a hidden entry.
+func (c Context) ListDir(name string) ([]FileEntry, error) {
+	infos, err := c.Files(name)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := append(append([]string{}, c.HidePatterns...), c.hiddenFilePatterns(name)...)
+
+	base := strings.TrimSuffix(c.Req.URL.Path, "/") + "/"
+
+	entries := make([]FileEntry, 0, len(infos))
+	for _, info := range infos {
+		if isHiddenName(info.Name(), patterns) {
+			continue
+		}
+		entries = append(entries, FileEntry{
+			Name:    info.Name(),
+			Size:    info.Size(),
+			IsDir:   info.IsDir(),
+			ModTime: info.ModTime(),
+			Mode:    info.Mode().String(),
+			URL:     base + info.Name(),
+		})
+	}
+
+	return entries, nil
+}
+
+// hiddenFileName is the sidecar file ListDir reads per-directory hide
+// patterns from, one shell glob per line (blank lines and lines
+// starting with "#" are ignored), the same way the static file
+// server's own hide option is configured, so a directory can add to
+// c.HidePatterns without a site-wide config change. It's always itself
+// treated as hidden.
+const hiddenFileName = ".hidden"
+
+// hiddenFilePatterns reads the hiddenFileName sidecar in the directory
+// named dir, if present, and returns its patterns plus hiddenFileName
+// itself. It returns just hiddenFileName if no sidecar exists or it
+// can't be read.
+func (c Context) hiddenFilePatterns(dir string) []string {
+	patterns := []string{hiddenFileName}
+
+	raw, err := c.readFile(path.Join(dir, hiddenFileName))
+	if err != nil {
+		return patterns
+	}
+
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// isHidden reports whether name matches one of c.HidePatterns.
+func (c Context) isHidden(name string) bool {
+	return isHiddenName(name, c.HidePatterns)
+}
+
+// isHiddenName reports whether name matches one of patterns.
+func isHiddenName(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ListDirSorted returns the same entries as ListDir, ordered by sortBy
+// ("name", "natural", "size", or "time"; anything else falls back to
+// "name") and order ("asc" or "desc"; anything else behaves as "asc").
+// "natural" compares names the way "name" does, except runs of digits
+// are compared numerically, so "file2" sorts before "file10".
+func (c Context) ListDirSorted(name, sortBy, order string) ([]FileEntry, error) {
+	entries, err := c.ListDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	sortFileEntries(entries, sortBy, order)
+	return entries, nil
+}
+
+// sortFileEntries sorts entries in place by sortBy ("name", "natural",
+// "size", or "time"; anything else falls back to "name") and order
+// ("asc" or "desc"; anything else behaves as "asc").
+func sortFileEntries(entries []FileEntry, sortBy, order string) {
+	var less func(i, j int) bool
+	switch sortBy {
+	case "size":
+		less = func(i, j int) bool { return entries[i].Size < entries[j].Size }
+	case "time":
+		less = func(i, j int) bool { return entries[i].ModTime.Before(entries[j].ModTime) }
+	case "natural":
+		less = func(i, j int) bool { return naturalLess(entries[i].Name, entries[j].Name) }
+	default:
+		less = func(i, j int) bool { return entries[i].Name < entries[j].Name }
+	}
+	if order == "desc" {
+		asc := less
+		less = func(i, j int) bool { return asc(j, i) }
+	}
+
+	sort.SliceStable(entries, less)
+}
+
+// filterFileEntries returns the entries whose Name contains query,
+// case-insensitively. An empty query returns entries unchanged.
+func filterFileEntries(entries []FileEntry, query string) []FileEntry {
+	if query == "" {
+		return entries
+	}
+
+	query = strings.ToLower(query)
+	filtered := make([]FileEntry, 0, len(entries))
+	for _, entry := range entries {
+		if strings.Contains(strings.ToLower(entry.Name), query) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// naturalDigits matches a run of one or more ASCII digits, for
+// naturalLess.
+var naturalDigits = regexp.MustCompile(`\d+`)
+
+// naturalLess reports whether a sorts before b under natural order:
+// split into alternating non-digit and digit runs, non-digit runs
+// compare as plain strings and digit runs compare as numbers (so "2"
+// sorts before "10"), falling back to a plain string comparison once
+// one side runs out of runs.
+func naturalLess(a, b string) bool {
+	aParts, bParts := naturalSplit(a), naturalSplit(b)
+
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aParts[i] == bParts[i] {
+			continue
+		}
+
+		aNum, aIsNum := naturalDigits.FindString(aParts[i]), naturalDigits.MatchString(aParts[i])
+		bNum, bIsNum := naturalDigits.FindString(bParts[i]), naturalDigits.MatchString(bParts[i])
+		if aIsNum && bIsNum && aParts[i] == aNum && bParts[i] == bNum {
+			aVal, aErr := strconv.Atoi(aNum)
+			bVal, bErr := strconv.Atoi(bNum)
+			if aErr == nil && bErr == nil {
+				return aVal < bVal
+			}
+		}
+
+		return aParts[i] < bParts[i]
+	}
+	return len(aParts) < len(bParts)
+}
+
+// naturalSplit splits s into alternating non-digit and digit runs, for
+// naturalLess.
+func naturalSplit(s string) []string {
+	var parts []string
+	last := 0
+	for _, loc := range naturalDigits.FindAllStringIndex(s, -1) {
+		if loc[0] > last {
+			parts = append(parts, s[last:loc[0]])
+		}
+		parts = append(parts, s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	if last < len(s) {
+		parts = append(parts, s[last:])
+	}
+	return parts
+}
+
+// ListDirPage returns up to limit entries of the directory named name,
+// starting at offset, along with the total number of entries in the
+// directory, for a browse directive to paginate huge directories.
+// Entries are still read and sorted in full first, so this bounds the
+// response size but not the per-request I/O and memory cost.
+func (c Context) ListDirPage(name string, offset, limit int) (entries []FileEntry, total int, err error) {
+	all, err := c.ListDir(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total = len(all)
+	if offset >= total {
+		return []FileEntry{}, total, nil
+	}
+
+	end := offset + limit
+	if end > total || limit <= 0 {
+		end = total
+	}
+
+	return all[offset:end], total, nil
+}
+
+// ListDirQuery returns up to limit entries of the directory named name,
+// filtered to those whose name contains query (case-insensitively, or
+// all entries if query is empty), sorted by sortBy/order as
+// ListDirSorted, and starting at offset, along with the total number
+// of entries matching query, for a browse directive to serve a search
+// box, sortable columns, and pagination together over directories with
+// tens of thousands of entries. As with ListDirPage, entries are still
+// read, filtered, and sorted in full first, so this bounds the response
+// size but not the per-request I/O and memory cost.
+func (c Context) ListDirQuery(name, query, sortBy, order string, offset, limit int) (entries []FileEntry, total int, err error) {
+	all, err := c.ListDir(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	filtered := filterFileEntries(all, query)
+	sortFileEntries(filtered, sortBy, order)
+
+	total = len(filtered)
+	if offset >= total {
+		return []FileEntry{}, total, nil
+	}
+
+	end := offset + limit
+	if end > total || limit <= 0 {
+		end = total
+	}
+
+	return filtered[offset:end], total, nil
+}
+
+// WriteZip walks the directory named name, relative to the site root,
+// and writes its contents as a zip archive to w, for a browse directive
+// to serve as a "download this folder" link.
+func (c Context) WriteZip(w io.Writer, name string) error {
+	zw := zip.NewWriter(w)
+
+	if err := c.addDirToZip(zw, name, ""); err != nil {
+		zw.Close()
+		return err
+	}
+
+	return zw.Close()
+}
+
+// addDirToZip recursively adds the contents of fsPath (a site-root
+// relative path) to zw, storing them under archivePath.
+func (c Context) addDirToZip(zw *zip.Writer, fsPath, archivePath string) error {
+	dir, err := c.Root.Open(fsPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		childFsPath := path.Join(fsPath, entry.Name())
+		childArchivePath := path.Join(archivePath, entry.Name())
+
+		if entry.IsDir() {
+			if err := c.addDirToZip(zw, childFsPath, childArchivePath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := c.addFileToZip(zw, childFsPath, childArchivePath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addFileToZip adds the single file at fsPath to zw under archivePath.
+func (c Context) addFileToZip(zw *zip.Writer, fsPath, archivePath string) error {
+	file, err := c.Root.Open(fsPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := zw.Create(archivePath)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// sidecarMetadata is the shape read from a .meta/<name>.json sidecar
+// file by ListDirWithMetadata.
+type sidecarMetadata struct {
+	Description string `json:"description"`
+}
+
+// BrowseTemplate maps a path prefix to the browse template that should
+// render directories under it, for a site that wants a distinct
+// listing template for e.g. "/downloads/" versus everywhere else.
+type BrowseTemplate struct {
+	PathPrefix string
+	Template   string
+}
+
+// BrowseTemplateFor returns the Template of whichever entry in
+// templates has the longest PathPrefix matching the current request
+// path, so a more specific prefix always wins over a shorter one
+// regardless of list order. It returns defaultTemplate if none match.
+func (c Context) BrowseTemplateFor(templates []BrowseTemplate, defaultTemplate string) string {
+	best := defaultTemplate
+	bestLen := -1
+
+	for _, bt := range templates {
+		if strings.HasPrefix(c.Req.URL.Path, bt.PathPrefix) && len(bt.PathPrefix) > bestLen {
+			best = bt.Template
+			bestLen = len(bt.PathPrefix)
+		}
+	}
+
+	return best
+}
+
+// ListDirWithMetadata returns the same entries as ListDir, with each
+// entry's Description populated from a ".meta/<name>.json" sidecar file
+// in the same directory, if one exists (e.g. "report.pdf" is described
+// by ".meta/report.pdf.json"). Entries without a sidecar are returned
+// unchanged, so authoring metadata for a directory listing is opt-in
+// per file.
+func (c Context) ListDirWithMetadata(name string) ([]FileEntry, error) {
+	entries, err := c.ListDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, entry := range entries {
+		if entry.IsDir {
+			continue
+		}
+		raw, err := c.readFile(path.Join(name, ".meta", entry.Name+".json"))
+		if err != nil {
+			continue
+		}
+		var meta sidecarMetadata
+		if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+			continue
+		}
+		entries[i].Description = meta.Description
+	}
+
+	return entries, nil
+}
+
+// FilesRecursive walks the directory tree rooted at name, relative to
+// the site root, and returns the path of every file (not directory)
+// whose base name matches pattern (a shell glob as accepted by
+// path/filepath.Match, e.g. "*.jpg"), for a template to build a
+// sitemap or gallery across nested directories. The walk stops
+// descending past maxDepth levels below name (1 means name and its
+// direct subdirectories) and returns at most maxCount matches, either of which being
+// <= 0 means unbounded, so a directive can cap the cost of walking a
+// large tree.
+func (c Context) FilesRecursive(name, pattern string, maxDepth, maxCount int) ([]string, error) {
+	var matches []string
+	err := c.walkFiles(name, pattern, 0, maxDepth, maxCount, &matches)
+	return matches, err
+}
+
+// walkFiles is the recursive implementation of FilesRecursive.
+func (c Context) walkFiles(name, pattern string, depth, maxDepth, maxCount int, matches *[]string) error {
+	dir, err := c.Root.Open(name)
+	if err != nil {
+		return err
+	}
+	infos, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	for _, info := range infos {
+		if maxCount > 0 && len(*matches) >= maxCount {
+			return nil
+		}
+
+		childPath := path.Join(name, info.Name())
+		if info.IsDir() {
+			if maxDepth > 0 && depth >= maxDepth {
+				continue
+			}
+			if err := c.walkFiles(childPath, pattern, depth+1, maxDepth, maxCount, matches); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if matched, err := path.Match(pattern, info.Name()); err == nil && matched {
+			*matches = append(*matches, childPath)
+		}
+	}
+
+	return nil
+}
+
+// ListDirJSON returns the same listing as ListDir, JSON-encoded, for a
+// directive to serve directly in response to an Accept: application/json
+// or ?format=json directory request.
+func (c Context) ListDirJSON(name string) (string, error) {
+	entries, err := c.ListDir(name)
+	if err != nil {
+		return "", err
+	}
+	return c.ToJSON(entries)
+}
+
+// galleryExtensions are the file extensions ListDirGallery treats as
+// images.
+var galleryExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg"}
+
+// ListDirGallery returns the same entries as ListDir, filtered down to
+// files with one of galleryExtensions, for a browse directive to render
+// as a thumbnail grid rather than a plain listing; each entry's URL can
+// be used directly as an <img> src, relying on the browser (or a
+// separate image directive) to size it as a thumbnail.
+func (c Context) ListDirGallery(name string) ([]FileEntry, error) {
+	entries, err := c.ListDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	gallery := make([]FileEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir || !MatchesExtension(entry.Name, galleryExtensions) {
+			continue
+		}
+		gallery = append(gallery, entry)
+	}
+
+	return gallery, nil
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// CanaryCookie is the cookie SelectCanary uses to remember which pool
+// member a client was assigned to, so repeated requests from the same
+// client keep landing on the same target instead of being re-rolled
+// every request.
+const CanaryCookie = "caddy_canary"
+
+// CanaryTarget is one weighted destination in a CanaryPool. Weight
+// controls how often it's chosen among the pool (higher is more
+// likely, 0 counts as 1).
+type CanaryTarget struct {
+	Name   string
+	Weight int
+}
+
+// ErrNoCanaryAvailable is returned by SelectCanary when pool has no
+// targets to choose from.
+var ErrNoCanaryAvailable = errors.New("middleware: no canary target available")
+
+// CanaryPool is a set of weighted destinations for SelectCanary, e.g.
+// {{"stable", 95}, {"canary", 5}} to send roughly 5% of traffic to a
+// canary upstream.
+type CanaryPool struct {
+	Targets []CanaryTarget
+}
+
+// SelectCanary returns the Name of the CanaryTarget the current
+// client is assigned to. If CanaryCookie already names a target still
+// present in pool, that assignment is kept, giving a client sticky
+// routing across requests instead of a fresh weighted roll every
+// time; otherwise a target is chosen at random weighted by Weight and
+// CanaryCookie is set so the choice sticks. A directive uses the
+// returned name to decide which upstream to proxy the request to,
+// e.g. by keying a map of upstream addresses.
+func (c Context) SelectCanary(pool CanaryPool) (string, error) {
+	if len(pool.Targets) == 0 {
+		return "", ErrNoCanaryAvailable
+	}
+
+	if sticky := c.Cookie(CanaryCookie); sticky != "" && canaryPoolHas(pool.Targets, sticky) {
+		return sticky, nil
+	}
+
+	choice := weightedCanaryChoice(pool.Targets)
+	c.SetCookie(CanaryCookie, choice)
+	return choice, nil
+}
+
+// canaryPoolHas reports whether targets contains a target named name.
+func canaryPoolHas(targets []CanaryTarget, name string) bool {
+	for _, target := range targets {
+		if target.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// weightedCanaryChoice picks one of targets at random, weighted by
+// Weight (a Weight of 0 counts as 1).
+func weightedCanaryChoice(targets []CanaryTarget) string {
+	total := 0
+	for _, target := range targets {
+		total += canaryWeight(target)
+	}
+
+	pick := rand.Intn(total)
+	for _, target := range targets {
+		pick -= canaryWeight(target)
+		if pick < 0 {
+			return target.Name
+		}
+	}
+	return targets[len(targets)-1].Name
+}
+
+// canaryWeight returns target.Weight, or 1 if it's zero.
+func canaryWeight(target CanaryTarget) int {
+	if target.Weight == 0 {
+		return 1
+	}
+	return target.Weight
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/url"
+	"strings"
+)
+
+// CanonicalHost redirects with code to the current request path on
+// canonicalHost if the request's Host doesn't already match it
+// case-insensitively, e.g. to enforce a single www vs. non-www or
+// lowercase host instead of one redir rule per permutation.
+func (c Context) CanonicalHost(canonicalHost string, code int) (string, error) {
+	if strings.EqualFold(c.Req.Host, canonicalHost) {
+		return "", nil
+	}
+	target := c.Scheme() + "://" + canonicalHost + c.Req.URL.RequestURI()
+	return c.Redirect(target, code)
+}
+
+// CanonicalTrailingSlash redirects with code to normalize the request
+// path's trailing slash according to policy: "add" ensures one is
+// present, "remove" strips it. The root path "/" and any other policy
+// value are left alone.
+func (c Context) CanonicalTrailingSlash(policy string, code int) (string, error) {
+	p := c.Req.URL.Path
+	if p == "/" {
+		return "", nil
+	}
+
+	var target string
+	switch policy {
+	case "add":
+		if strings.HasSuffix(p, "/") {
+			return "", nil
+		}
+		target = p + "/"
+	case "remove":
+		if !strings.HasSuffix(p, "/") {
+			return "", nil
+		}
+		target = strings.TrimSuffix(p, "/")
+	default:
+		return "", nil
+	}
+
+	if q := c.Req.URL.RawQuery; q != "" {
+		target += "?" + q
+	}
+	return c.Redirect(target, code)
+}
+
+// CanonicalOptions configures CanonicalRedirect, folding the checks
+// CanonicalHost and CanonicalTrailingSlash perform separately, plus
+// path case, into a single pass. Any zero-value field leaves that
+// aspect of the request unchecked.
+type CanonicalOptions struct {
+	// Host, if set, is the canonical hostname (e.g. "example.com" to
+	// redirect "www.example.com" to it, or the reverse).
+	Host string
+
+	// TrailingSlash is "add" or "remove", matching the policy values
+	// CanonicalTrailingSlash accepts. Any other value, including "",
+	// leaves the trailing slash alone. It's never applied to "/".
+	TrailingSlash string
+
+	// LowercasePath redirects a path containing uppercase characters
+	// to its lowercased form.
+	LowercasePath bool
+}
+
+// CanonicalRedirect issues a single code redirect (via Redirect)
+// applying every mismatch opts describes, for a `canonical` directive
+// that enforces host, trailing-slash, and path-case policy together
+// instead of chaining CanonicalHost and CanonicalTrailingSlash and
+// sending a visitor through multiple redirect hops. It returns "",
+// nil (no redirect) if the request already matches every policy opts
+// configures.
+func (c Context) CanonicalRedirect(opts CanonicalOptions, code int) (string, error) {
+	host := c.Req.Host
+	requestPath := c.Req.URL.Path
+	changed := false
+
+	if opts.Host != "" && !strings.EqualFold(host, opts.Host) {
+		host = opts.Host
+		changed = true
+	}
+	if opts.LowercasePath {
+		if lower := strings.ToLower(requestPath); lower != requestPath {
+			requestPath = lower
+			changed = true
+		}
+	}
+	if requestPath != "/" {
+		switch opts.TrailingSlash {
+		case "add":
+			if !strings.HasSuffix(requestPath, "/") {
+				requestPath += "/"
+				changed = true
+			}
+		case "remove":
+			if strings.HasSuffix(requestPath, "/") {
+				requestPath = strings.TrimSuffix(requestPath, "/")
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return "", nil
+	}
+
+	target := url.URL{Scheme: c.Scheme(), Host: host, Path: requestPath, RawQuery: c.Req.URL.RawQuery}
+	return c.Redirect(target.String(), code)
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// CaseInsensitiveFileSystem wraps an http.FileSystem so Open resolves
+// a path segment by segment case-insensitively when no exact match
+// exists, for a site migrated from a case-insensitive server (e.g.
+// IIS) whose existing links differ in case from the files actually on
+// disk. Unicode NFC-normalizing the request path before it reaches
+// Open would need golang.org/x/text/unicode/norm, which this tree has
+// no module/dependency management to add; case folding alone (via
+// strings.EqualFold, which is already Unicode-aware) covers the
+// described IIS-migration scenario.
+type CaseInsensitiveFileSystem struct {
+	Inner http.FileSystem
+}
+
+// Open opens name from fs.Inner, falling back to a case-insensitive,
+// segment-by-segment resolution against fs.Inner's directory tree if
+// the exact name doesn't exist.
+func (fs CaseInsensitiveFileSystem) Open(name string) (http.File, error) {
+	if file, err := fs.Inner.Open(name); err == nil {
+		return file, nil
+	}
+
+	resolved, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Inner.Open(resolved)
+}
+
+// resolve finds the real, on-disk path matching name by comparing
+// each path segment case-insensitively against fs.Inner's directory
+// listings.
+func (fs CaseInsensitiveFileSystem) resolve(name string) (string, error) {
+	clean := strings.Trim(path.Clean("/"+name), "/")
+	if clean == "" {
+		return "/", nil
+	}
+
+	current := "/"
+	for _, segment := range strings.Split(clean, "/") {
+		dir, err := fs.Inner.Open(current)
+		if err != nil {
+			return "", err
+		}
+		entries, err := dir.Readdir(-1)
+		dir.Close()
+		if err != nil {
+			return "", err
+		}
+
+		found := ""
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Name(), segment) {
+				found = entry.Name()
+				break
+			}
+		}
+		if found == "" {
+			return "", os.ErrNotExist
+		}
+		current = path.Join(current, found)
+	}
+	return current, nil
+}
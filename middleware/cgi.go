@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http/cgi"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrCGIScriptNotFound is returned by ServeCGI when the request path
+// doesn't resolve to an existing script under scriptRoot.
+var ErrCGIScriptNotFound = errors.New("middleware: cgi script not found")
+
+// ServeCGI executes the CGI script the request path resolves to under
+// scriptRoot (a real filesystem directory, since CGI scripts are OS
+// executables rather than http.FileSystem entries), passing the
+// standard CGI environment variables via net/http/cgi, and writes its
+// output directly to c.ResponseWriter. It acquires a slot from
+// limiter under key (releasing it once the script exits) the same way
+// AcquireConcurrencySlot/ReleaseConcurrencySlot do, to bound how many
+// scripts run at once, and cancels the script if it runs past
+// timeout. For legacy scripts that aren't FastCGI.
+func (c Context) ServeCGI(scriptRoot, key string, limiter *ConcurrencyLimiter, maxConcurrent, maxQueue int, timeout time.Duration) error {
+	scriptPath := filepath.Join(scriptRoot, filepath.Clean("/"+c.Req.URL.Path))
+	if scriptPath != filepath.Clean(scriptRoot) && !strings.HasPrefix(scriptPath, filepath.Clean(scriptRoot)+string(filepath.Separator)) {
+		return ErrCGIScriptNotFound
+	}
+	if info, err := os.Stat(scriptPath); err != nil || info.IsDir() {
+		return ErrCGIScriptNotFound
+	}
+
+	if err := limiter.Acquire(key, maxConcurrent, maxQueue, timeout); err != nil {
+		return err
+	}
+	defer limiter.Release(key)
+
+	ctx, cancel := context.WithTimeout(c.Req.Context(), timeout)
+	defer cancel()
+
+	handler := &cgi.Handler{Path: scriptPath, Root: "/"}
+	handler.ServeHTTP(c.ResponseWriter, c.Req.WithContext(ctx))
+	return nil
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreakerStatus is the state of one key's circuit in a
+// CircuitBreaker.
+type CircuitBreakerStatus int
+
+const (
+	// CircuitClosed is the normal state: requests are allowed through.
+	CircuitClosed CircuitBreakerStatus = iota
+	// CircuitOpen rejects requests outright until its cooldown elapses.
+	CircuitOpen
+	// CircuitHalfOpen lets probe requests through to test whether the
+	// upstream has recovered, until RecordSuccess or RecordFailure
+	// resolves it (see Allow).
+	CircuitHalfOpen
+)
+
+// CircuitBreaker tracks per-key failure counts to protect a failing
+// upstream from further load, keyed by an arbitrary string (e.g. an
+// upstream address), the way RateLimiter protects one from excess
+// volume. The zero value is ready to use.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	circuits map[string]*circuitState
+}
+
+// circuitState tracks one key's status and its failure count within
+// the current window.
+type circuitState struct {
+	status      CircuitBreakerStatus
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+}
+
+// Allow reports whether a request keyed by key may proceed to the
+// upstream at now, given cooldown to wait after the circuit opens
+// before probing recovery. A directive calls this once per request
+// before proxying, serving a 503 (or a fallback upstream) itself when
+// it returns false, and reports the outcome afterward via
+// RecordSuccess or RecordFailure. While a probe issued during
+// CircuitHalfOpen is outstanding, Allow keeps returning true for the
+// same key until RecordSuccess or RecordFailure resolves it; a
+// directive that proxies requests serially per upstream won't
+// double-probe, but one issuing them concurrently may let a few
+// through before the first result lands.
+func (b *CircuitBreaker) Allow(key string, now time.Time, cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.stateFor(key)
+	if state.status != CircuitOpen {
+		return true
+	}
+	if now.Sub(state.openedAt) < cooldown {
+		return false
+	}
+	state.status = CircuitHalfOpen
+	return true
+}
+
+// RecordSuccess reports that the request allowed for key succeeded,
+// closing the circuit and resetting its failure count.
+func (b *CircuitBreaker) RecordSuccess(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.stateFor(key)
+	state.status = CircuitClosed
+	state.failures = 0
+}
+
+// RecordFailure reports that the request allowed for key failed (an
+// error response, or one slower than a directive's own latency
+// threshold), opening the circuit at now once failureThreshold
+// failures have occurred within window. A failure during
+// CircuitHalfOpen reopens the circuit immediately, regardless of
+// failureThreshold, since the probe it was given already answered the
+// "has it recovered" question.
+func (b *CircuitBreaker) RecordFailure(key string, now time.Time, failureThreshold int, window time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.stateFor(key)
+	if state.status == CircuitHalfOpen {
+		state.status = CircuitOpen
+		state.openedAt = now
+		state.failures = 0
+		return
+	}
+
+	if now.Sub(state.windowStart) > window {
+		state.windowStart = now
+		state.failures = 0
+	}
+	state.failures++
+	if state.failures >= failureThreshold {
+		state.status = CircuitOpen
+		state.openedAt = now
+	}
+}
+
+// Status reports key's current CircuitBreakerStatus without side
+// effects (unlike Allow, it never transitions CircuitOpen to
+// CircuitHalfOpen after cooldown elapses), for a readiness check that
+// wants to know an upstream's state rather than decide whether to let
+// a request through.
+func (b *CircuitBreaker) Status(key string) CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.stateFor(key).status
+}
+
+// stateFor returns key's circuitState, creating one in CircuitClosed
+// if this is the first time key has been seen.
+func (b *CircuitBreaker) stateFor(key string) *circuitState {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuitState)
+	}
+	state, ok := b.circuits[key]
+	if !ok {
+		state = &circuitState{}
+		b.circuits[key] = state
+	}
+	return state
+}
+
+// CircuitAllowed reports whether the current request may proceed to
+// the upstream named key under breaker's circuit, given cooldown, for
+// a `circuit_breaker` directive deciding whether to proxy or serve a
+// fallback response immediately.
+func (c Context) CircuitAllowed(breaker *CircuitBreaker, key string, cooldown time.Duration) bool {
+	return breaker.Allow(key, c.Now(), cooldown)
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrConcurrencyLimitQueueFull is returned by
+// ConcurrencyLimiter.Acquire when a key's wait queue is already at
+// maxQueue and can't accept another waiter.
+var ErrConcurrencyLimitQueueFull = errors.New("middleware: concurrency limit wait queue is full")
+
+// ErrConcurrencyLimitTimeout is returned by ConcurrencyLimiter.Acquire
+// when a request waited longer than timeout for a slot to free up.
+var ErrConcurrencyLimitTimeout = errors.New("middleware: timed out waiting for a concurrency slot")
+
+// ConcurrencyLimiter bounds the number of in-flight requests per key
+// (e.g. an upstream address), queuing callers past that limit up to a
+// bounded depth rather than piling them up unboundedly, for a
+// directive protecting a slow backend from exhausting file
+// descriptors under load. The zero value is ready to use.
+type ConcurrencyLimiter struct {
+	mu    sync.Mutex
+	pools map[string]*concurrencyPool
+}
+
+// concurrencyPool is one key's in-flight slots and current queue
+// depth.
+type concurrencyPool struct {
+	slots  chan struct{}
+	queued int
+}
+
+// Acquire blocks until a slot is available for key, immediately if
+// fewer than maxConcurrent requests are already in flight for it.
+// Once maxConcurrent is reached, up to maxQueue further callers wait
+// for a slot to free, each for at most timeout; a caller arriving
+// once the queue is already at maxQueue gets
+// ErrConcurrencyLimitQueueFull instead of waiting at all, and a
+// waiter that times out gets ErrConcurrencyLimitTimeout. A successful
+// Acquire must be paired with a Release once the request finishes.
+func (l *ConcurrencyLimiter) Acquire(key string, maxConcurrent, maxQueue int, timeout time.Duration) error {
+	l.mu.Lock()
+	pool := l.poolFor(key, maxConcurrent)
+	l.mu.Unlock()
+
+	select {
+	case pool.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.mu.Lock()
+	if pool.queued >= maxQueue {
+		l.mu.Unlock()
+		return ErrConcurrencyLimitQueueFull
+	}
+	pool.queued++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		pool.queued--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case pool.slots <- struct{}{}:
+		return nil
+	case <-time.After(timeout):
+		return ErrConcurrencyLimitTimeout
+	}
+}
+
+// Release frees the slot Acquire reserved for key, letting a queued
+// waiter (or the next Acquire) proceed.
+func (l *ConcurrencyLimiter) Release(key string) {
+	l.mu.Lock()
+	pool := l.pools[key]
+	l.mu.Unlock()
+	if pool == nil {
+		return
+	}
+	<-pool.slots
+}
+
+// poolFor returns key's concurrencyPool, creating one sized for
+// maxConcurrent slots the first time key is seen. Must be called with
+// l.mu held.
+func (l *ConcurrencyLimiter) poolFor(key string, maxConcurrent int) *concurrencyPool {
+	if l.pools == nil {
+		l.pools = make(map[string]*concurrencyPool)
+	}
+	pool, ok := l.pools[key]
+	if !ok {
+		pool = &concurrencyPool{slots: make(chan struct{}, maxConcurrent)}
+		l.pools[key] = pool
+	}
+	return pool
+}
+
+// AcquireConcurrencySlot reserves an in-flight slot for key under
+// limiter, for a `concurrency_limit` directive to call before
+// proxying to an upstream and release (via ReleaseConcurrencySlot)
+// once the response has been served. Keying by c.Host() with one
+// shared limiter caps concurrent requests per virtual host, so one
+// noisy site on a shared instance can't starve the others; capping
+// bandwidth or raw connection counts per site needs, respectively, a
+// rate-shaping writer and the listener/accept-loop layer, neither of
+// which this limiter (a request-scoped in-flight counter) covers.
+func (c Context) AcquireConcurrencySlot(limiter *ConcurrencyLimiter, key string, maxConcurrent, maxQueue int, timeout time.Duration) error {
+	return limiter.Acquire(key, maxConcurrent, maxQueue, timeout)
+}
+
+// ReleaseConcurrencySlot releases the slot AcquireConcurrencySlot
+// reserved for key under limiter.
+func (c Context) ReleaseConcurrencySlot(limiter *ConcurrencyLimiter, key string) {
+	limiter.Release(key)
+}
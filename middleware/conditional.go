@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotModified is returned by ConditionalContent when it has already
+// written a 304 Not Modified response, so template execution should
+// stop without rendering any body, the same way ErrRedirect signals an
+// already-issued redirect.
+var ErrNotModified = errors.New("middleware: not modified response issued, template execution aborted")
+
+// ConditionalContent computes a strong ETag for content and sets it on
+// the response, then compares it against the request's If-None-Match
+// header. If the client already holds a matching copy, it writes 304
+// Not Modified and returns ErrNotModified so template execution stops
+// with no body rendered; otherwise it returns content unchanged. This
+// lets middleware-generated output (Include, Markdown, ListDir, ...)
+// participate in conditional requests the same way a raw static file
+// already does, e.g. {{.ConditionalContent (.Markdown "post.md")}}. It
+// does not implement byte-range responses: templated content is
+// generated fresh on every request rather than read from a seekable
+// file, so there's no stable underlying resource to slice a range out
+// of.
+func (c Context) ConditionalContent(content string) (string, error) {
+	sum := sha256.Sum256([]byte(content))
+	etag := `"` + hex.EncodeToString(sum[:])[:16] + `"`
+
+	c.ResponseWriter.Header().Set("ETag", etag)
+
+	if inm := c.Req.Header.Get("If-None-Match"); inm != "" && inm == etag {
+		c.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return "", ErrNotModified
+	}
+
+	return content, nil
+}
+
+// WeakETagForFile returns a weak ETag (RFC 7232 W/"...") for name
+// derived from its current size and modification time rather than its
+// content, and validates it the same way ConditionalContent does. This
+// is the cheaper "mtime+size" alternative to ConditionalContent's
+// content-hash ETag, for a large or rarely-changing file where hashing
+// the full content on every request isn't worth it.
+func (c Context) WeakETagForFile(name string) (string, error) {
+	file, err := c.Root.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	etag := fmt.Sprintf(`W/"%x-%x"`, info.ModTime().Unix(), info.Size())
+	c.ResponseWriter.Header().Set("ETag", etag)
+
+	if inm := c.Req.Header.Get("If-None-Match"); inm != "" && inm == etag {
+		c.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return "", ErrNotModified
+	}
+
+	return etag, nil
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import "net/http"
+
+// HeaderRule is one rule for a ConditionalHeaderWriter: when Match
+// reports true for the response's eventual status code and Content-
+// Type, Name is set to Value, or deleted if Value is empty.
+type HeaderRule struct {
+	Match func(statusCode int, contentType string) bool
+	Name  string
+	Value string
+}
+
+// ConditionalHeaderWriter wraps an http.ResponseWriter and evaluates
+// Rules against the response's final status code and Content-Type
+// only once that's known — right before the status line and headers
+// are actually written — for a header directive that wants to add or
+// remove headers based on outcome (e.g. HSTS only on 2xx/3xx, a CSP
+// only on text/html) rather than unconditionally, the way SetHeader
+// and friends do. This is the "defer until written" half of that
+// directive; the header directive itself, and any config syntax for
+// it, lives outside this package.
+type ConditionalHeaderWriter struct {
+	http.ResponseWriter
+	Rules   []HeaderRule
+	applied bool
+}
+
+// WrapConditionalHeaders returns rw wrapped in a
+// *ConditionalHeaderWriter that applies rules once the response's
+// status code is known.
+func WrapConditionalHeaders(rw http.ResponseWriter, rules []HeaderRule) *ConditionalHeaderWriter {
+	return &ConditionalHeaderWriter{ResponseWriter: rw, Rules: rules}
+}
+
+// apply evaluates and applies w.Rules against statusCode and the
+// response's current Content-Type, once.
+func (w *ConditionalHeaderWriter) apply(statusCode int) {
+	if w.applied {
+		return
+	}
+	w.applied = true
+
+	contentType := w.ResponseWriter.Header().Get("Content-Type")
+	for _, rule := range w.Rules {
+		if !rule.Match(statusCode, contentType) {
+			continue
+		}
+		if rule.Value == "" {
+			w.ResponseWriter.Header().Del(rule.Name)
+		} else {
+			w.ResponseWriter.Header().Set(rule.Name, rule.Value)
+		}
+	}
+}
+
+// WriteHeader applies w.Rules against code before writing it.
+func (w *ConditionalHeaderWriter) WriteHeader(code int) {
+	w.apply(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write applies w.Rules against an implicit 200 OK, as
+// http.ResponseWriter.Write documents, before writing b.
+func (w *ConditionalHeaderWriter) Write(b []byte) (int, error) {
+	w.apply(http.StatusOK)
+	return w.ResponseWriter.Write(b)
+}
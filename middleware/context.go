@@ -0,0 +1,1843 @@
+// Package middleware holds the types that enable Caddy directives to
+// interoperate.
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Context is the context with which Caddy templates are executed.
+type Context struct {
+	Root http.FileSystem
+	Req  *http.Request
+
+	// ResponseWriter is the writer for the response to Req. It is used
+	// by the cookie mutation helpers and by Redirect to write the
+	// response headers that templates can't otherwise reach.
+	ResponseWriter http.ResponseWriter
+
+	// TrustedProxies holds the CIDR ranges of reverse proxies that are
+	// trusted to set forwarding headers (Forwarded, X-Forwarded-For,
+	// X-Forwarded-Proto, X-Real-IP). ClientIP and Scheme only consult
+	// these headers when Req.RemoteAddr falls within one of these
+	// ranges.
+	TrustedProxies []net.IPNet
+
+	// EgressPolicy restricts which hosts HTTPInclude and Webhook may
+	// reach. The zero value allows everything.
+	EgressPolicy EgressPolicy
+
+	// RenderLimiter, if set, bounds how many Markdown and top-level
+	// Include renders run concurrently (see RenderSemaphore).
+	// RenderTimeout is how long a render waits for a free slot before
+	// failing with ErrRenderQueueTimeout; left at its zero value, it
+	// times out immediately once the limit is reached. Left nil,
+	// RenderLimiter leaves rendering unbounded.
+	RenderLimiter *RenderSemaphore
+	RenderTimeout time.Duration
+
+	// Args holds the arguments passed to Include for the template
+	// currently being executed, accessible as {{.Args}}. It is empty
+	// for the top-level template.
+	Args []interface{}
+
+	// IncludeRoot, if set, is where Include looks up filenames instead
+	// of Root. This lets a site serve its full webroot for static
+	// content and Files() while restricting server-side template
+	// includes to a narrower, non-public directory of partials.
+	IncludeRoot http.FileSystem
+
+	// LeftDelim and RightDelim override the default "{{" and "}}"
+	// template action delimiters, for sites whose templates need "{{"
+	// to appear literally (e.g. templates that also feed a JS
+	// framework). Leaving either empty keeps that side's default.
+	LeftDelim  string
+	RightDelim string
+
+	// HidePatterns holds shell globs (as accepted by path/filepath.Match)
+	// of entry names that ListDir should omit from directory listings,
+	// e.g. ".git" or "*.tmp".
+	HidePatterns []string
+
+	// Vars holds extra data made available to templates as {{.Var "key"}},
+	// e.g. values a directive resolved per matched path (custom labels,
+	// a per-section template variant flag, and so on).
+	Vars map[string]interface{}
+
+	// FileMode and DirMode set the permissions middlewares that write
+	// to Root (currently SaveUploadedFile and SaveUploadedFileRandom)
+	// use for new files and directories, respectively. Left at their
+	// zero value, they default to 0644 and 0755.
+	FileMode os.FileMode
+	DirMode  os.FileMode
+
+	// ServerVersion is the running server's version string, set once at
+	// startup and exposed to templates as {{.ServerVersion}} for status
+	// pages and footers.
+	ServerVersion string
+
+	// SiteAddress and SiteRoot identify the site block currently
+	// serving the request (e.g. "example.com:443" and
+	// "/var/www/example.com"), set once when the site's Context is
+	// built. A template shared across many sites (via Include or
+	// RenderLayout) reads them to adapt links and labels to whichever
+	// vhost is actually serving it, rather than hardcoding one site's
+	// values.
+	SiteAddress string
+	SiteRoot    string
+
+	// ServerStartTime is when the running server started, used by
+	// Uptime. Left at its zero value, Uptime always reports 0.
+	ServerStartTime time.Time
+
+	// MaxIncludeDepth caps how many Includes may nest inside one
+	// another before Include fails with ErrIncludeDepthExceeded, so
+	// that a runaway chain of includes can't recurse until the stack
+	// blows up. Left at its zero value, it defaults to 10.
+	MaxIncludeDepth int
+
+	// includeChain holds the filenames of the Includes currently
+	// executing, innermost last, so Include can detect a cycle and
+	// enforce MaxIncludeDepth. It is threaded down through nested
+	// Include calls the same way Args is, without affecting the
+	// caller's own includeChain.
+	includeChain []string
+
+	// FollowSymlinks allows Include and RenderLayout to read a file
+	// that resolves, via a symlink, to a location outside the site
+	// root (or IncludeRoot). Left false (the default), they refuse
+	// such paths with an error wrapping ErrIncludeEscapesRoot instead
+	// of silently following the link, since the ".." protection
+	// http.Dir provides is purely lexical and doesn't account for
+	// symlinks.
+	FollowSymlinks bool
+}
+
+// Uptime returns how long the server has been running, computed from
+// ServerStartTime, for a status page or footer to display without
+// hardcoding a value that drifts from reality.
+func (c Context) Uptime() time.Duration {
+	if c.ServerStartTime.IsZero() {
+		return 0
+	}
+	return time.Since(c.ServerStartTime)
+}
+
+// Var returns the value of Vars[key], or nil if key isn't present or
+// Vars is nil.
+func (c Context) Var(key string) interface{} {
+	return c.Vars[key]
+}
+
+// checkIncludeBounds returns ErrIncludeEscapesRoot if filename resolves,
+// via a symlink, to a location outside includeRoot(), unless
+// FollowSymlinks is set. If includeRoot() isn't an http.Dir, or the
+// path can't be resolved (e.g. it doesn't exist), it returns nil and
+// leaves any real error to surface from the subsequent Open.
+func (c Context) checkIncludeBounds(filename string) error {
+	return c.checkSymlinkBounds(c.includeRoot(), filename)
+}
+
+// checkRootBounds returns ErrIncludeEscapesRoot if filename resolves,
+// via a symlink, to a location outside c.Root, unless FollowSymlinks
+// is set. It applies checkIncludeBounds' protection to callers (e.g.
+// TableOfContents) that read a caller-named file straight from the
+// site root rather than through Include/RenderLayout's IncludeRoot.
+func (c Context) checkRootBounds(filename string) error {
+	return c.checkSymlinkBounds(c.Root, filename)
+}
+
+// checkSymlinkBounds returns ErrIncludeEscapesRoot if filename
+// resolves, via a symlink, to a location outside root, unless
+// FollowSymlinks is set. If root isn't an http.Dir, or the path can't
+// be resolved (e.g. it doesn't exist), it returns nil and leaves any
+// real error to surface from the subsequent Open.
+func (c Context) checkSymlinkBounds(root http.FileSystem, filename string) error {
+	if c.FollowSymlinks {
+		return nil
+	}
+
+	dir, ok := root.(http.Dir)
+	if !ok {
+		return nil
+	}
+
+	rootReal, err := filepath.EvalSymlinks(string(dir))
+	if err != nil {
+		return nil
+	}
+
+	real, err := filepath.EvalSymlinks(joinUnderRoot(dir, filename))
+	if err != nil {
+		return nil
+	}
+
+	if !withinRoot(rootReal, real) {
+		return fmt.Errorf("%w: %s", ErrIncludeEscapesRoot, filename)
+	}
+	return nil
+}
+
+// includeRoot returns the file system Include should read from: Root,
+// unless IncludeRoot is set.
+func (c Context) includeRoot() http.FileSystem {
+	if c.IncludeRoot != nil {
+		return c.IncludeRoot
+	}
+	return c.Root
+}
+
+// ErrRedirect is returned by Redirect to abort further template
+// execution once a redirect has been issued.
+var ErrRedirect = errors.New("middleware: redirect issued, template execution aborted")
+
+// ErrIncludeCycle is returned by Include when filename is already being
+// included further up the include chain.
+var ErrIncludeCycle = errors.New("middleware: include cycle detected")
+
+// ErrIncludeDepthExceeded is returned by Include when nesting Includes
+// would exceed MaxIncludeDepth.
+var ErrIncludeDepthExceeded = errors.New("middleware: maximum include depth exceeded")
+
+// ErrIncludeEscapesRoot is returned by Include and RenderLayout when
+// filename resolves, via a symlink, to a location outside the site
+// root or IncludeRoot, and FollowSymlinks isn't set.
+var ErrIncludeEscapesRoot = errors.New("middleware: include path escapes the site root via a symlink")
+
+// defaultMaxIncludeDepth is the include nesting limit used when
+// MaxIncludeDepth is unset.
+const defaultMaxIncludeDepth = 10
+
+// funcMap holds functions registered with RegisterTemplateFunction, in
+// addition to the built-in actions exposed as Context methods.
+var funcMap = template.FuncMap{}
+var funcMapMu sync.RWMutex
+
+// RegisterTemplateFunction makes fn available to templates under name,
+// alongside the built-in Context methods. It is meant to be called from
+// an init function by code (e.g. another directive) that wants to
+// extend what templates can do; registering the same name twice
+// overwrites the earlier registration.
+func RegisterTemplateFunction(name string, fn interface{}) {
+	funcMapMu.Lock()
+	defer funcMapMu.Unlock()
+	funcMap[name] = fn
+}
+
+// templateFuncs returns a snapshot of the registered functions, safe to
+// hand to template.Funcs without holding funcMapMu while templates
+// execute.
+func templateFuncs() template.FuncMap {
+	funcMapMu.RLock()
+	defer funcMapMu.RUnlock()
+
+	snapshot := make(template.FuncMap, len(funcMap))
+	for name, fn := range funcMap {
+		snapshot[name] = fn
+	}
+	return snapshot
+}
+
+// bufferPool holds *bytes.Buffer values reused across template
+// executions, to cut down on allocations for the common case of many
+// small includes per request.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// cachedTemplate is a parsed template together with the mtime of the
+// file it was parsed from, so a later lookup can tell whether the file
+// has changed since.
+type cachedTemplate struct {
+	tpl     *template.Template
+	modTime time.Time
+}
+
+// templateCache holds parsed templates, keyed by filename, shared by
+// all Contexts in the process. Templates are re-parsed whenever the
+// underlying file's mtime changes. Since the key is just the filename,
+// two sites that both Include a same-named file with different content
+// (different Root) will thrash each other's cache entry; this is an
+// acceptable trade-off for the common case of one site per process.
+var templateCache sync.Map
+
+// parseTemplate returns the parsed template for filename, reusing a
+// cached parse if the file's mtime hasn't changed since it was last
+// parsed.
+func (c Context) parseTemplate(filename string) (*template.Template, error) {
+	if err := c.checkIncludeBounds(filename); err != nil {
+		return nil, err
+	}
+
+	file, err := c.includeRoot().Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err == nil {
+		if cached, ok := templateCache.Load(filename); ok {
+			ct := cached.(cachedTemplate)
+			if ct.modTime.Equal(info.ModTime()) {
+				return ct.tpl, nil
+			}
+		}
+	}
+
+	body, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl, err := template.New(filename).Delims(c.LeftDelim, c.RightDelim).Funcs(templateFuncs()).Parse(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if info != nil {
+		templateCache.Store(filename, cachedTemplate{tpl: tpl, modTime: info.ModTime()})
+	}
+
+	return tpl, nil
+}
+
+// Include reads filename from the site root (or from IncludeRoot, if
+// set), executes it as a template with c as the data, and returns the
+// result. Any args are made
+// available to the included template as {{.Args}}, without affecting
+// the caller's own Args. If execution aborts because a template action
+// called Redirect, Include returns an empty string and an error
+// wrapping ErrRedirect, so that an Include nested inside another
+// template propagates the abort to its own caller rather than letting
+// the enclosing template keep rendering. Only the original, non-template
+// caller of Include should treat ErrRedirect as "already handled" and
+// discard it; Include itself never swallows it.
+//
+// Include also guards against two files including each other and
+// against nesting deeper than MaxIncludeDepth, returning an error
+// wrapping ErrIncludeCycle or ErrIncludeDepthExceeded instead of
+// recursing until the stack overflows.
+func (c Context) Include(filename string, args ...interface{}) (string, error) {
+	for _, included := range c.includeChain {
+		if included == filename {
+			return "", fmt.Errorf("%w: %s", ErrIncludeCycle, filename)
+		}
+	}
+
+	if len(c.includeChain) == 0 {
+		release, err := c.RenderLimiter.acquire(c.RenderTimeout)
+		if err != nil {
+			return "", err
+		}
+		defer release()
+	}
+
+	maxDepth := c.MaxIncludeDepth
+	if maxDepth == 0 {
+		maxDepth = defaultMaxIncludeDepth
+	}
+	if len(c.includeChain) >= maxDepth {
+		return "", fmt.Errorf("%w: %s", ErrIncludeDepthExceeded, filename)
+	}
+
+	tpl, err := c.parseTemplate(filename)
+	if err != nil {
+		return "", err
+	}
+
+	c.Args = args
+	c.includeChain = append(append([]string{}, c.includeChain...), filename)
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := tpl.Execute(buf, c); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// readIncludeFile reads filename the same way Include does: from
+// IncludeRoot if set, otherwise Root.
+func (c Context) readIncludeFile(filename string) (string, error) {
+	if err := c.checkIncludeBounds(filename); err != nil {
+		return "", err
+	}
+
+	file, err := c.includeRoot().Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	body, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// RenderLayout executes layoutFilename as a template with c as data,
+// after also parsing contentFilename into the same template set under
+// its own name, so any {{define "name"}} contentFilename declares
+// fills a matching {{block "name"}}...{{end}} placeholder in the
+// layout. This lets a site wrap every page in one shared layout
+// instead of hand-rolling header/footer Includes on every page.
+// Unlike Include, RenderLayout doesn't participate in the parsed
+// template cache, since the pair of files it combines isn't a single
+// cacheable unit.
+func (c Context) RenderLayout(layoutFilename, contentFilename string) (string, error) {
+	layoutBody, err := c.readIncludeFile(layoutFilename)
+	if err != nil {
+		return "", err
+	}
+	contentBody, err := c.readIncludeFile(contentFilename)
+	if err != nil {
+		return "", err
+	}
+
+	tpl, err := template.New(layoutFilename).Delims(c.LeftDelim, c.RightDelim).Funcs(templateFuncs()).Parse(layoutBody)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tpl.New(contentFilename).Parse(contentBody); err != nil {
+		return "", err
+	}
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := tpl.Execute(buf, c); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// RenderString executes templateText (e.g. a proxied or FastCGI
+// upstream's response body) as a template with c as data, and returns
+// the result, for an opt-in "template proxied responses" directive
+// option so a backend that isn't template-aware itself can still use
+// edge-side includes and personalization at the server. Restricting
+// this to a content type or path is a directive-level decision the
+// same way any other directive would check the response's
+// Content-Type against a Matcher; nothing here forces it. Unlike
+// Include, RenderString doesn't participate in the parsed template
+// cache, since the content varies request to request instead of being
+// a stable file backing a cache key: the template is parsed fresh
+// every call.
+func (c Context) RenderString(templateText string, args ...interface{}) (string, error) {
+	if len(c.includeChain) == 0 {
+		release, err := c.RenderLimiter.acquire(c.RenderTimeout)
+		if err != nil {
+			return "", err
+		}
+		defer release()
+	}
+
+	tpl, err := template.New("proxied-response").Delims(c.LeftDelim, c.RightDelim).Funcs(templateFuncs()).Parse(templateText)
+	if err != nil {
+		return "", err
+	}
+
+	c.Args = args
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := tpl.Execute(buf, c); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Markdown reads filename from the site root and renders it from
+// Markdown to HTML. The result is returned as template.HTML so that it
+// is inserted into the page verbatim rather than being escaped like a
+// plain string.
+func (c Context) Markdown(filename string) (template.HTML, error) {
+	release, err := c.RenderLimiter.acquire(c.RenderTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	body, err := c.readFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	_, body = parseFrontMatter(body)
+	return template.HTML(renderMarkdown(body)), nil
+}
+
+// MarkdownWithOptions renders filename like Markdown, with opts
+// controlling optional block behaviors (e.g. hard line breaks) for
+// directives that need Markdown extensions toggled per block.
+func (c Context) MarkdownWithOptions(filename string, opts MarkdownOptions) (template.HTML, error) {
+	release, err := c.RenderLimiter.acquire(c.RenderTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	body, err := c.readFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	_, body = parseFrontMatter(body)
+	return template.HTML(renderMarkdownOpts(body, opts)), nil
+}
+
+// FrontMatter parses and returns the YAML (---), TOML (+++), or JSON
+// ({...}) metadata block at the top of filename, e.g. title, date,
+// tags or draft, without rendering the rest of the document. It
+// returns an empty map if filename has no recognized front matter.
+func (c Context) FrontMatter(filename string) (map[string]interface{}, error) {
+	body, err := c.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, _ := parseFrontMatter(body)
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	return metadata, nil
+}
+
+// Layout resolves the template that filename's content should be
+// rendered with: layouts[type] if filename's front-matter "type" key
+// names an entry in layouts, otherwise "_layout.html" alongside
+// filename, e.g. for a markdown directive to route different
+// front-matter types to different designs instead of one global
+// template. It returns the conventional fallback even if filename has
+// no front matter or can't be read.
+func (c Context) Layout(filename string, layouts map[string]string) string {
+	metadata, err := c.FrontMatter(filename)
+	if err == nil {
+		if pageType, ok := metadata["type"].(string); ok && pageType != "" {
+			if layout, ok := layouts[pageType]; ok {
+				return layout
+			}
+		}
+	}
+	return path.Join(path.Dir(filename), "_layout.html")
+}
+
+// LayoutForExtension is like Layout, but keys layouts by filename's
+// extension (e.g. ".tpl", ".html") instead of front-matter "type", for
+// a templates directive that wants different file extensions to render
+// with different designs rather than opting each page in via front
+// matter.
+func (c Context) LayoutForExtension(filename string, layouts map[string]string) string {
+	if layout, ok := layouts[path.Ext(filename)]; ok {
+		return layout
+	}
+	return path.Join(path.Dir(filename), "_layout.html")
+}
+
+// DelimPair is a template action delimiter pair, e.g. {"[[", "]]"}.
+type DelimPair struct {
+	Left, Right string
+}
+
+// DelimsForExtension returns the delimiter pair configured for
+// filename's extension in delims, or c.LeftDelim/c.RightDelim if
+// delims has no entry for that extension, for a templates directive
+// serving both plain .html pages and .tpl files that also embed a
+// client-side framework's own "{{ }}" syntax.
+func (c Context) DelimsForExtension(filename string, delims map[string]DelimPair) (left, right string) {
+	if pair, ok := delims[path.Ext(filename)]; ok {
+		return pair.Left, pair.Right
+	}
+	return c.LeftDelim, c.RightDelim
+}
+
+// readFile reads filename, relative to the site root, into a string.
+func (c Context) readFile(filename string) (string, error) {
+	file, err := c.Root.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	body, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// Env returns a map of the process's environment variables, keyed by
+// name, for use as {{.Env.NAME}} in templates.
+func (c Context) Env() map[string]string {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+// httpIncludeClient is used by HTTPInclude to fetch remote content. It
+// carries a timeout so that a slow or unresponsive upstream can't hang
+// template execution indefinitely.
+var httpIncludeClient = &http.Client{Timeout: 10 * time.Second}
+
+// HTTPInclude fetches url with an HTTP GET request and returns its
+// response body as a string, for embedding remote content in a
+// template the way Include embeds a local file. It returns
+// ErrEgressDenied without making the request if url's host is blocked
+// by c.EgressPolicy.
+func (c Context) HTTPInclude(url string) (string, error) {
+	if err := checkEgress(c.EgressPolicy, url); err != nil {
+		return "", err
+	}
+
+	resp, err := httpIncludeClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// Webhook sends payload as the body of an HTTP POST to url with
+// Content-Type application/json, using httpIncludeClient's timeout,
+// and returns the response status code. A directive firing a webhook
+// on a matched request (e.g. a file download) renders payload from a
+// template with the placeholders it needs first, then calls this once
+// the response has otherwise been served; it doesn't provide batching
+// or a retry queue, since that needs a background worker this
+// per-request Context has no lifetime to run one in. It returns
+// ErrEgressDenied without making the request if url's host is blocked
+// by c.EgressPolicy.
+func (c Context) Webhook(url, payload string) (int, error) {
+	if err := checkEgress(c.EgressPolicy, url); err != nil {
+		return 0, err
+	}
+
+	resp, err := httpIncludeClient.Post(url, "application/json", strings.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Files reads the directory named name, relative to the site root, and
+// returns its entries sorted by filename, for use as {{range .Files "."}}
+// in a directory-listing template.
+func (c Context) Files(name string) ([]os.FileInfo, error) {
+	dir, err := c.Root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// FileExists reports whether name, relative to the site root, exists
+// and can be opened, for a rewrite condition like "send to index.html
+// unless the file exists".
+func (c Context) FileExists(name string) bool {
+	f, err := c.Root.Open(name)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// Cookie gets the value of the cookie named name.
+func (c Context) Cookie(name string) string {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// CookieObj returns the full *http.Cookie named name, or nil if no such
+// cookie is present on the request. Note that browsers only ever send
+// the Name and Value of a cookie back in the Cookie header, so
+// attributes like Path, Domain, Expires and MaxAge are never populated
+// here; they're only meaningful on cookies your own site sets with
+// SetCookie.
+func (c Context) CookieObj(name string) *http.Cookie {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	return cookie
+}
+
+// Cookies returns all cookies present on the request.
+func (c Context) Cookies() []*http.Cookie {
+	return c.Req.Cookies()
+}
+
+// CookieOption configures an outgoing cookie set by SetCookie.
+type CookieOption func(*http.Cookie)
+
+// CookiePath sets the cookie's Path attribute.
+func CookiePath(path string) CookieOption {
+	return func(cookie *http.Cookie) { cookie.Path = path }
+}
+
+// CookieDomain sets the cookie's Domain attribute.
+func CookieDomain(domain string) CookieOption {
+	return func(cookie *http.Cookie) { cookie.Domain = domain }
+}
+
+// CookieExpires sets the cookie's Expires attribute.
+func CookieExpires(expires time.Time) CookieOption {
+	return func(cookie *http.Cookie) { cookie.Expires = expires }
+}
+
+// CookieMaxAge sets the cookie's MaxAge attribute, in seconds.
+func CookieMaxAge(maxAge int) CookieOption {
+	return func(cookie *http.Cookie) { cookie.MaxAge = maxAge }
+}
+
+// CookieSecure marks the cookie as Secure.
+func CookieSecure(secure bool) CookieOption {
+	return func(cookie *http.Cookie) { cookie.Secure = secure }
+}
+
+// CookieHTTPOnly marks the cookie as HttpOnly.
+func CookieHTTPOnly(httpOnly bool) CookieOption {
+	return func(cookie *http.Cookie) { cookie.HttpOnly = httpOnly }
+}
+
+// CookieSameSite sets the cookie's SameSite attribute. mode should be one
+// of http.SameSiteLaxMode, http.SameSiteStrictMode, or
+// http.SameSiteNoneMode.
+func CookieSameSite(mode http.SameSite) CookieOption {
+	return func(cookie *http.Cookie) { cookie.SameSite = mode }
+}
+
+// SetCookie writes a Set-Cookie header for a cookie named name with
+// value value to ResponseWriter, applying any opts to the outgoing
+// cookie.
+func (c Context) SetCookie(name, value string, opts ...CookieOption) {
+	cookie := &http.Cookie{Name: name, Value: value}
+	for _, opt := range opts {
+		opt(cookie)
+	}
+	http.SetCookie(c.ResponseWriter, cookie)
+}
+
+// DeleteCookie writes a Set-Cookie header to ResponseWriter that
+// instructs the client to delete the cookie named name.
+func (c Context) DeleteCookie(name string) {
+	http.SetCookie(c.ResponseWriter, &http.Cookie{Name: name, Value: "", MaxAge: -1, Expires: time.Unix(0, 0)})
+}
+
+// RewriteResponseCookies rereads every Set-Cookie header already
+// written to the response (e.g. by a proxied legacy upstream), passes
+// each through mutate, and rewrites the header with the result,
+// dropping a cookie mutate returns false for. Use this to harden or
+// strip cookies an upstream sets that this site doesn't otherwise
+// control, e.g. adding Secure/HttpOnly/SameSite to a legacy backend's
+// session cookie.
+func (c Context) RewriteResponseCookies(mutate func(*http.Cookie) bool) {
+	existing := (&http.Response{Header: c.ResponseWriter.Header()}).Cookies()
+	c.ResponseWriter.Header().Del("Set-Cookie")
+
+	for _, cookie := range existing {
+		if mutate(cookie) {
+			http.SetCookie(c.ResponseWriter, cookie)
+		}
+	}
+}
+
+// StripRequestCookies removes the Cookie header from the current
+// request before it reaches the rest of the handler chain, for a
+// directive applied to a cacheable path where a session cookie would
+// otherwise force a shared cache to treat every visitor as unique.
+func (c Context) StripRequestCookies() {
+	c.Req.Header.Del("Cookie")
+}
+
+// Redirect writes an HTTP redirect to location with status code to
+// ResponseWriter and returns ErrRedirect so that template execution
+// stops and no further body content is rendered.
+func (c Context) Redirect(location string, code int) (string, error) {
+	http.Redirect(c.ResponseWriter, c.Req, location, code)
+	return "", ErrRedirect
+}
+
+// RedirectRegex redirects like Redirect, except pattern is matched as
+// a regular expression against the current request path and target
+// may reference its capture groups with $1, $2, etc. (see
+// regexp.Regexp.ReplaceAllString), for bulk site-migration redirects
+// that would otherwise need one line per old URL. If pattern doesn't
+// match the current path, no redirect happens and both return values
+// are zero.
+func (c Context) RedirectRegex(pattern, target string, code int) (string, error) {
+	re, err := compiledRegexp(pattern)
+	if err != nil {
+		return "", err
+	}
+	if !re.MatchString(c.Req.URL.Path) {
+		return "", nil
+	}
+	return c.Redirect(re.ReplaceAllString(c.Req.URL.Path, target), code)
+}
+
+// MetaRedirect serves a minimal HTML page with a meta refresh to
+// location after delaySeconds, for clients that mishandle a Location
+// header, or to hide the destination from simple scrapers that don't
+// execute HTML. Unlike Redirect, it writes 200 OK, since the actual
+// navigation happens client-side.
+func (c Context) MetaRedirect(location string, delaySeconds int) (string, error) {
+	c.ResponseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return fmt.Sprintf(
+		`<!DOCTYPE html><html><head><meta http-equiv="refresh" content="%d;url=%s"></head><body></body></html>`,
+		delaySeconds, html.EscapeString(location),
+	), nil
+}
+
+// RespondWithStatus writes code as the response status and returns an
+// empty string, so a template can set an unusual status (e.g. 404 for
+// a "not found" branch) without redirecting or aborting execution.
+func (c Context) RespondWithStatus(code int) string {
+	c.ResponseWriter.WriteHeader(code)
+	return ""
+}
+
+// RespondFixed writes a fixed response: each header in headers, then
+// code as the status, then body, and returns an empty string, for a
+// `status`/`respond` directive returning a canned response (e.g.
+// "respond /old-api 410") for matching paths without needing a file
+// on disk or the ErrorPage machinery. Restricting it to matching
+// paths is a directive-level Matcher check over PathTarget, the same
+// as any other conditional directive behavior.
+func (c Context) RespondFixed(code int, body string, headers map[string]string) string {
+	for name, value := range headers {
+		c.ResponseWriter.Header().Set(name, value)
+	}
+	c.ResponseWriter.WriteHeader(code)
+	io.WriteString(c.ResponseWriter, body)
+	return ""
+}
+
+// Rewrite parses target and replaces Req.URL with it, so that
+// subsequent calls to URI and PathMatches reflect the new URL. Host
+// and Port are unaffected, since they read the Host header rather
+// than Req.URL.
+func (c Context) Rewrite(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	if c.Req.Header.Get(originalURIHeader) == "" {
+		c.Req.Header.Set(originalURIHeader, c.Req.RequestURI)
+	}
+	c.Req.URL = u
+	c.Req.RequestURI = u.RequestURI()
+	return "", nil
+}
+
+// RewriteRegex rewrites the request path like Rewrite, except pattern
+// is matched as a regular expression against the current path and
+// replacement may reference its capture groups with $1, $2, etc., or,
+// for a group defined with the (?P<name>...) syntax, ${name} (see
+// regexp.Regexp.Expand). If pattern doesn't match the current path,
+// the request is left unchanged.
+func (c Context) RewriteRegex(pattern, replacement string) (string, error) {
+	re, err := compiledRegexp(pattern)
+	if err != nil {
+		return "", err
+	}
+	if !re.MatchString(c.Req.URL.Path) {
+		return "", nil
+	}
+	return c.Rewrite(re.ReplaceAllString(c.Req.URL.Path, replacement))
+}
+
+// QueryMode controls how RedirectWithQuery/RewriteWithQuery handle
+// the current request's query string when computing a new URL.
+type QueryMode int
+
+const (
+	// QueryDrop discards the current request's query string; the new
+	// URL's own query string (if any) is used as-is.
+	QueryDrop QueryMode = iota
+
+	// QueryPreserve keeps the current request's query string
+	// unchanged, used only if the new URL doesn't already have one of
+	// its own.
+	QueryPreserve
+
+	// QueryMerge combines the current request's query string with any
+	// query parameters already present on the new URL, with the new
+	// URL's values winning on a key collision — e.g. for appending a
+	// tracking parameter via a target like "/new?utm_source=migration"
+	// while still keeping whatever query the client sent.
+	QueryMerge
+)
+
+// applyQueryMode returns target with its query string adjusted per
+// mode against the current request's query string.
+func (c Context) applyQueryMode(target string, mode QueryMode) (string, error) {
+	if mode == QueryDrop {
+		return target, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+
+	switch mode {
+	case QueryPreserve:
+		if u.RawQuery == "" {
+			u.RawQuery = c.Req.URL.RawQuery
+		}
+	case QueryMerge:
+		merged := c.Req.URL.Query()
+		for key, values := range u.Query() {
+			merged[key] = values
+		}
+		u.RawQuery = merged.Encode()
+	}
+	return u.String(), nil
+}
+
+// RedirectWithQuery behaves like Redirect, additionally applying mode
+// to control whether the current request's query string is dropped,
+// preserved as-is, or merged with any query parameters already on
+// location, for a `redir` directive that needs explicit query
+// handling instead of hand-building the target with the {query}
+// placeholder.
+func (c Context) RedirectWithQuery(location string, code int, mode QueryMode) (string, error) {
+	resolved, err := c.applyQueryMode(location, mode)
+	if err != nil {
+		return "", err
+	}
+	return c.Redirect(resolved, code)
+}
+
+// RewriteWithQuery behaves like Rewrite, applying mode the same way
+// RedirectWithQuery does.
+func (c Context) RewriteWithQuery(target string, mode QueryMode) (string, error) {
+	resolved, err := c.applyQueryMode(target, mode)
+	if err != nil {
+		return "", err
+	}
+	return c.Rewrite(resolved)
+}
+
+// RegexCapture matches pattern against the current request path and
+// returns the named capture group group's value, for use outside a
+// RewriteRegex replacement string, e.g. a header value that should
+// echo part of the path: {{.RegexCapture "^/t/(?P<tenant>[^/]+)" "tenant"}}.
+// It returns "" if pattern doesn't match or defines no such group.
+func (c Context) RegexCapture(pattern, group string) (string, error) {
+	re, err := compiledRegexp(pattern)
+	if err != nil {
+		return "", err
+	}
+	match := re.FindStringSubmatch(c.Req.URL.Path)
+	if match == nil {
+		return "", nil
+	}
+	for i, name := range re.SubexpNames() {
+		if name == group && i < len(match) {
+			return match[i], nil
+		}
+	}
+	return "", nil
+}
+
+// HostLabel matches pattern (e.g. "^(?P<tenant>[^.]+)\\.example\\.com$")
+// against the current request's Host and returns the named capture
+// group group's value, for a site block matched by a wildcard or
+// regex host label to route per-tenant off the matched portion, e.g.
+// {{.HostLabel "^(?P<tenant>[^.]+)\\.example\\.com$" "tenant"}}. It
+// returns "" if pattern doesn't match Host or defines no such group.
+func (c Context) HostLabel(pattern, group string) (string, error) {
+	re, err := compiledRegexp(pattern)
+	if err != nil {
+		return "", err
+	}
+	match := re.FindStringSubmatch(c.Req.Host)
+	if match == nil {
+		return "", nil
+	}
+	for i, name := range re.SubexpNames() {
+		if name == group && i < len(match) {
+			return match[i], nil
+		}
+	}
+	return "", nil
+}
+
+// IndexFile returns the first name in names that exists as a file
+// within dir (relative to the site root), joined with path.Join, for
+// a directive resolving a directory request to an index file with a
+// configurable candidate list, e.g. IndexFile(".", "index.htm",
+// "default.html"). Passing no names (an "index off" configuration)
+// always returns "", false, so the caller falls through to a 404 or a
+// directory listing instead of assuming index.html.
+func (c Context) IndexFile(dir string, names ...string) (string, bool) {
+	for _, name := range names {
+		candidate := path.Join(dir, name)
+		if c.FileExists(candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// TryFiles rewrites the request to the first of targets that exists on
+// disk, falling back to the last target unconditionally if none of the
+// others do, e.g. TryFiles("{path}", "{path}/", "/index.php?{query}")
+// for a PHP front controller or single-page app. Each target may
+// contain the literal placeholder {query}, replaced with the request's
+// current raw query string.
+func (c Context) TryFiles(targets ...string) (string, error) {
+	if len(targets) == 0 {
+		return "", nil
+	}
+
+	for i, target := range targets {
+		expanded := strings.ReplaceAll(target, "{query}", c.Req.URL.RawQuery)
+
+		path := expanded
+		if idx := strings.IndexAny(path, "?#"); idx >= 0 {
+			path = path[:idx]
+		}
+
+		if i == len(targets)-1 || c.FileExists(path) {
+			return c.Rewrite(expanded)
+		}
+	}
+
+	return "", nil
+}
+
+// Query gets the value of the query string parameter named name. If
+// name is repeated in the query string, the first value is returned.
+func (c Context) Query(name string) string {
+	return c.Req.URL.Query().Get(name)
+}
+
+// Queries returns the parsed query string of the request.
+func (c Context) Queries() url.Values {
+	return c.Req.URL.Query()
+}
+
+// Form gets the value of the request parameter named name, from either
+// the URL query string or, for POST/PUT/PATCH requests with an
+// x-www-form-urlencoded or multipart body, the parsed form body.
+func (c Context) Form(name string) string {
+	return c.Req.FormValue(name)
+}
+
+// PostForm gets the value of the parameter named name from the parsed
+// form body only, ignoring the URL query string.
+func (c Context) PostForm(name string) string {
+	return c.Req.PostFormValue(name)
+}
+
+// FormFileName returns the client-supplied filename of the multipart
+// file field named fieldName, without reading its contents, so a
+// template can display or validate the name of a file a visitor is
+// about to upload (see SaveUploadedFile to actually store it).
+func (c Context) FormFileName(fieldName string) (string, error) {
+	file, header, err := c.Req.FormFile(fieldName)
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	return header.Filename, nil
+}
+
+// ToUpper returns s with all letters mapped to their upper case.
+func (c Context) ToUpper(s string) string {
+	return strings.ToUpper(s)
+}
+
+// ToLower returns s with all letters mapped to their lower case.
+func (c Context) ToLower(s string) string {
+	return strings.ToLower(s)
+}
+
+// Replace returns s with all occurrences of old replaced with new.
+func (c Context) Replace(s, old, new string) string {
+	return strings.ReplaceAll(s, old, new)
+}
+
+// Split slices s into substrings separated by sep.
+func (c Context) Split(s, sep string) []string {
+	return strings.Split(s, sep)
+}
+
+// Join concatenates the elements of a, separated by sep.
+func (c Context) Join(a []string, sep string) string {
+	return strings.Join(a, sep)
+}
+
+// Trim returns s with leading and trailing Unicode code points
+// contained in cutset removed.
+func (c Context) Trim(s, cutset string) string {
+	return strings.Trim(s, cutset)
+}
+
+// TrimSpace returns s with leading and trailing white space removed.
+func (c Context) TrimSpace(s string) string {
+	return strings.TrimSpace(s)
+}
+
+// StripHTML returns s with all HTML tags removed. It is a plain
+// scanner, not an HTML parser: it doesn't understand malformed markup
+// or decode entities, so it should only be used on trusted or
+// already-sanitized input.
+func (c Context) StripHTML(s string) string {
+	var out strings.Builder
+	var inTag bool
+
+	for _, r := range s {
+		switch {
+		case inTag:
+			if r == '>' {
+				inTag = false
+			}
+		case r == '<':
+			inTag = true
+		default:
+			out.WriteRune(r)
+		}
+	}
+
+	return out.String()
+}
+
+// StripExt returns s with its file extension, if any, removed, for
+// turning a filename like "post.md" into a pretty URL slug like "post".
+func (c Context) StripExt(s string) string {
+	return strings.TrimSuffix(s, path.Ext(s))
+}
+
+// HTMLEscape escapes s so that it can be inserted into an HTML
+// document as literal text.
+func (c Context) HTMLEscape(s string) string {
+	return html.EscapeString(s)
+}
+
+// Now returns the current local time, for use with time.Time's own
+// formatting methods, e.g. {{(.Now).Format "Jan 2, 2006"}}.
+func (c Context) Now() time.Time {
+	return time.Now()
+}
+
+// NowDate returns the current local date with the time of day
+// truncated to midnight, for date-only comparisons and formatting.
+func (c Context) NowDate() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// ParseTime parses value using layout (as accepted by time.Parse, e.g.
+// time.RFC3339), for turning a timestamp string pulled from a JSON,
+// CSV, or YAML data file into a time.Time a template can compare and
+// format with its own Format method, the same way .Now and .NowDate
+// already can.
+func (c Context) ParseTime(layout, value string) (time.Time, error) {
+	return time.Parse(layout, value)
+}
+
+// ToJSON encodes v as a JSON string, for embedding structured data
+// (e.g. from .FromJSON, .Query, or .Files) into a template.
+func (c Context) ToJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// FromJSON decodes data as JSON, so a template can iterate over or
+// index into it. The result is a map[string]interface{}, []interface{},
+// or scalar, depending on the shape of data.
+func (c Context) FromJSON(data string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// JSON reads filename, relative to the site root, and decodes it as
+// JSON via FromJSON, so a template can walk a data file with dot/index
+// syntax, e.g. {{(.JSON "config.json").server.port}}, instead of the
+// author pre-rendering it into static snippets.
+func (c Context) JSON(filename string) (interface{}, error) {
+	data, err := c.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return c.FromJSON(data)
+}
+
+// SetHeader sets the response header field name to value, replacing
+// any existing values for that field.
+func (c Context) SetHeader(name, value string) string {
+	c.ResponseWriter.Header().Set(name, value)
+	return ""
+}
+
+// AddHeader appends value to the response header field name, leaving
+// any existing values for that field in place.
+func (c Context) AddHeader(name, value string) string {
+	c.ResponseWriter.Header().Add(name, value)
+	return ""
+}
+
+// RemoveHeader deletes the response header field name, e.g. to strip
+// a "Server" or "X-Powered-By" value set by an upstream.
+func (c Context) RemoveHeader(name string) string {
+	c.ResponseWriter.Header().Del(name)
+	return ""
+}
+
+// SetHeaderIfAbsent sets the response header field name to value only
+// if it doesn't already have a value.
+func (c Context) SetHeaderIfAbsent(name, value string) string {
+	if c.ResponseWriter.Header().Get(name) == "" {
+		c.ResponseWriter.Header().Set(name, value)
+	}
+	return ""
+}
+
+// SetRequestHeader sets the incoming request header field name to
+// value, replacing any existing values, so a later handler in the
+// chain (e.g. a proxy or fastcgi transport) sees the new value.
+func (c Context) SetRequestHeader(name, value string) string {
+	c.Req.Header.Set(name, value)
+	return ""
+}
+
+// RemoveRequestHeader deletes the incoming request header field name,
+// e.g. to scrub a spoofable client-supplied header before it reaches
+// later handlers.
+func (c Context) RemoveRequestHeader(name string) string {
+	c.Req.Header.Del(name)
+	return ""
+}
+
+// PushedTargets records which HTTP/2 server push targets have already
+// been pushed on a connection, so Push can dedupe repeated or
+// circular push requests (e.g. two pages that both push a shared
+// stylesheet, or a push target whose response would itself trigger
+// the same push). The zero value is ready to use.
+type PushedTargets struct {
+	mu     sync.Mutex
+	pushed map[string]bool
+}
+
+// markIfNew records target as pushed and reports whether it was new,
+// i.e. hadn't already been recorded.
+func (p *PushedTargets) markIfNew(target string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pushed == nil {
+		p.pushed = make(map[string]bool)
+	}
+	if p.pushed[target] {
+		return false
+	}
+	p.pushed[target] = true
+	return true
+}
+
+// Push initiates an HTTP/2 server push of target via the response's
+// http.Pusher (see ResponseWriterWrapper.Push), returning
+// http.ErrNotSupported if the underlying connection isn't HTTP/2. If
+// target has already been pushed on pushed, it's a no-op returning
+// nil, so a `push` directive listing the same resource for several
+// paths (or a resource whose own push list loops back to it) doesn't
+// push it more than once.
+func (c Context) Push(pushed *PushedTargets, target string, opts *http.PushOptions) error {
+	pusher, ok := c.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	if !pushed.markIfNew(target) {
+		return nil
+	}
+	return pusher.Push(target, opts)
+}
+
+// ServerTiming appends one entry to the response's Server-Timing
+// header (https://www.w3.org/TR/server-timing/) describing a phase of
+// request handling, e.g. {{.ServerTiming "tls" 4.2 "TLS handshake"}},
+// so a frontend team can see the server-side breakdown in browser dev
+// tools. description may be empty to omit the "desc" field.
+func (c Context) ServerTiming(name string, durationMs float64, description string) string {
+	entry := fmt.Sprintf("%s;dur=%g", name, durationMs)
+	if description != "" {
+		entry += fmt.Sprintf(";desc=%q", description)
+	}
+	c.ResponseWriter.Header().Add("Server-Timing", entry)
+	return ""
+}
+
+// LimitRequestBody caps the request body at maxBytes: a later read
+// past that limit fails with an error, protecting a slow-reading
+// backend (fastcgi, proxy, or an upload handler) from a client
+// holding the connection open with an oversized or never-ending body.
+// A directive calls this once, early, before anything reads Req.Body.
+func (c Context) LimitRequestBody(maxBytes int64) string {
+	c.Req.Body = http.MaxBytesReader(c.ResponseWriter, c.Req.Body, maxBytes)
+	return ""
+}
+
+// SetExpires sets both the Cache-Control and Expires response headers
+// for a maxAgeSeconds caching policy, appending the immutable
+// directive when immutable is true, for static assets whose content
+// never changes at a given URL (e.g. filenames containing a content
+// hash).
+func (c Context) SetExpires(maxAgeSeconds int, immutable bool) string {
+	cacheControl := fmt.Sprintf("public, max-age=%d", maxAgeSeconds)
+	if immutable {
+		cacheControl += ", immutable"
+	}
+	c.ResponseWriter.Header().Set("Cache-Control", cacheControl)
+	c.ResponseWriter.Header().Set("Expires", c.Now().Add(time.Duration(maxAgeSeconds)*time.Second).UTC().Format(http.TimeFormat))
+	return ""
+}
+
+// SetExpiresForExtension is SetExpires for a table of rules keyed by
+// file extension (as DelimsForExtension is to LeftDelim/RightDelim),
+// so a caller can apply one Cache-Control/Expires policy per file
+// type instead of a header block per extension. name's extension not
+// appearing in rules is a no-op.
+func (c Context) SetExpiresForExtension(name string, rules map[string]int, immutable bool) string {
+	maxAgeSeconds, ok := rules[path.Ext(name)]
+	if !ok {
+		return ""
+	}
+	return c.SetExpires(maxAgeSeconds, immutable)
+}
+
+// ServeContentType sets the response's Content-Type header explicitly
+// to contentType and, when noSniff is true, also sets
+// X-Content-Type-Options: nosniff, so a browser won't second-guess an
+// unusual or missing extension (e.g. a user-uploaded file served
+// without one) and render it as something more dangerous than
+// intended, such as HTML.
+func (c Context) ServeContentType(contentType string, noSniff bool) string {
+	c.ResponseWriter.Header().Set("Content-Type", contentType)
+	if noSniff {
+		c.ResponseWriter.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+	return ""
+}
+
+// Truncate returns the first length runes of s. If s is longer than
+// length, "..." is appended so the truncation is visible to the reader.
+func (c Context) Truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	return string(runes[:length]) + "..."
+}
+
+// WordCount returns the number of whitespace-separated words in s.
+func (c Context) WordCount(s string) int {
+	return len(strings.Fields(s))
+}
+
+// Hostname returns the hostname of the machine Caddy is running on, as
+// reported by the OS, or an empty string if it can't be determined.
+func (c Context) Hostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// Header gets the value of the request header with field name.
+func (c Context) Header(name string) string {
+	return c.Req.Header.Get(name)
+}
+
+// IP gets the IP address of the immediate peer that made the request,
+// based on Req.RemoteAddr. Behind a load balancer or reverse proxy this
+// is the proxy's address, not the original client's; use ClientIP
+// instead if TrustedProxies is configured for the proxies in front of
+// this site.
+//
+// RemoteAddr without a port (either bare, or a bracketed IPv6/zoned
+// address) is handled by stripping the brackets rather than treating
+// the missing port as an error, since some listeners and test harnesses
+// populate RemoteAddr that way. A malformed address (mismatched
+// brackets, too many colons) returns "" rather than echoing the raw
+// value back, since it isn't a usable IP.
+func (c Context) IP() string {
+	ip, _, err := net.SplitHostPort(c.Req.RemoteAddr)
+	if err == nil {
+		return ip
+	}
+	if !strings.Contains(err.Error(), "missing port") {
+		return ""
+	}
+
+	host := c.Req.RemoteAddr
+	if stripped, ok := stripHostBrackets(host); ok {
+		return stripped
+	}
+	if strings.ContainsAny(host, "[]") {
+		return ""
+	}
+	return host
+}
+
+// stripHostBrackets reports whether host is wrapped in exactly one
+// pair of brackets ("[...]") with no nested brackets, and if so returns
+// its unwrapped content.
+func stripHostBrackets(host string) (string, bool) {
+	if len(host) < 2 || host[0] != '[' || host[len(host)-1] != ']' {
+		return "", false
+	}
+	inner := host[1 : len(host)-1]
+	if strings.ContainsAny(inner, "[]") {
+		return "", false
+	}
+	return inner, true
+}
+
+// ClientIP returns the address of the original client that made the
+// request. If the immediate peer (Req.RemoteAddr) is in
+// TrustedProxies, it walks the RFC 7239 Forwarded: for= values
+// right-to-left, falling back to X-Forwarded-For and then
+// X-Real-IP, and returns the first address that is not itself a
+// trusted proxy. If the immediate peer is not trusted, it behaves
+// exactly like IP.
+func (c Context) ClientIP() string {
+	peer := c.IP()
+	if !c.isTrustedProxy(peer) {
+		return peer
+	}
+
+	hops := forwardedForHops(c.Req.Header.Get("Forwarded"))
+	if len(hops) == 0 {
+		if xff := c.Req.Header.Get("X-Forwarded-For"); xff != "" {
+			for _, hop := range strings.Split(xff, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !c.isTrustedProxy(hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+
+	if xRealIP := c.Req.Header.Get("X-Real-IP"); xRealIP != "" {
+		return xRealIP
+	}
+
+	return peer
+}
+
+// ApplyRealIP overwrites Req.RemoteAddr with the result of ClientIP,
+// so that IP, logging, and anything else reading RemoteAddr downstream
+// of this call see the original client's address consistently, rather
+// than each needing to call ClientIP for itself. The original port is
+// discarded, since the real client's port isn't meaningful once
+// forwarded through a proxy.
+func (c Context) ApplyRealIP() {
+	c.Req.RemoteAddr = net.JoinHostPort(c.ClientIP(), "0")
+}
+
+// Scheme returns the scheme ("http" or "https") of the request. If the
+// immediate peer is in TrustedProxies, it is derived from the
+// Forwarded: proto= parameter, falling back to X-Forwarded-Proto.
+func (c Context) Scheme() string {
+	scheme := "http"
+	if c.Req.TLS != nil {
+		scheme = "https"
+	}
+
+	if !c.isTrustedProxy(c.IP()) {
+		return scheme
+	}
+
+	if proto, ok := forwardedParam(c.Req.Header.Get("Forwarded"), "proto"); ok {
+		return proto
+	}
+	if proto := c.Req.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+
+	return scheme
+}
+
+// Proto is an alias for Scheme, named after the Forwarded "proto="
+// parameter it consults.
+func (c Context) Proto() string {
+	return c.Scheme()
+}
+
+// HTTPVersion returns the request's HTTP protocol version as reported
+// by the standard library, e.g. "HTTP/1.1" or "HTTP/2.0" — not to be
+// confused with Proto/Scheme, which report "http"/"https". HTTP/3
+// isn't distinguishable this way, since recognizing it needs a
+// QUIC-aware listener this tree's net/http-only stack doesn't have; a
+// request that reached this server over HTTP/3 through a terminating
+// proxy in front of it reports whatever protocol that proxy negotiated
+// on its own connection to us.
+func (c Context) HTTPVersion() string {
+	return c.Req.Proto
+}
+
+// ForwardedPort returns the port the original client connected to, for
+// a request that passed through a reverse proxy in front of this
+// server. If the immediate peer is in TrustedProxies, it is derived
+// from the Forwarded: host= parameter (when it includes a port),
+// falling back to X-Forwarded-Port; otherwise, and when neither header
+// is present, it falls back to Port.
+func (c Context) ForwardedPort() (string, error) {
+	if c.isTrustedProxy(c.IP()) {
+		if host, ok := forwardedParam(c.Req.Header.Get("Forwarded"), "host"); ok {
+			if _, port, err := net.SplitHostPort(host); err == nil {
+				return port, nil
+			}
+		}
+		if port := c.Req.Header.Get("X-Forwarded-Port"); port != "" {
+			return port, nil
+		}
+	}
+	return c.Port()
+}
+
+// isTrustedProxy reports whether ip falls within one of c.TrustedProxies.
+func (c Context) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(strings.Trim(ip, "[]"))
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range c.TrustedProxies {
+		if cidr.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedForHops extracts, in order, the for= values of an RFC 7239
+// Forwarded header. Malformed headers yield no hops rather than an
+// error, so callers can fall back to X-Forwarded-For.
+func forwardedForHops(header string) []string {
+	var hops []string
+	for _, part := range strings.Split(header, ",") {
+		if val, ok := forwardedElementParam(part, "for"); ok {
+			hops = append(hops, val)
+		}
+	}
+	return hops
+}
+
+// forwardedParam returns the value of the named parameter from the
+// first element of an RFC 7239 Forwarded header that carries it.
+func forwardedParam(header, name string) (string, bool) {
+	for _, part := range strings.Split(header, ",") {
+		if val, ok := forwardedElementParam(part, name); ok {
+			return val, true
+		}
+	}
+	return "", false
+}
+
+// forwardedElementParam returns the value of the named parameter
+// within a single comma-separated element of a Forwarded header, e.g.
+// "for=192.0.2.60;proto=http". IPv6 for= values are returned with
+// their brackets intact; ports are stripped.
+func forwardedElementParam(element, name string) (string, bool) {
+	for _, kv := range strings.Split(element, ";") {
+		kv = strings.TrimSpace(kv)
+		prefix := name + "="
+		if !strings.HasPrefix(strings.ToLower(kv), prefix) {
+			continue
+		}
+		val := strings.Trim(kv[len(prefix):], `"`)
+		if val == "" {
+			continue
+		}
+		if name == "for" {
+			val = stripForwardedPort(val)
+		}
+		return val, true
+	}
+	return "", false
+}
+
+// stripForwardedPort removes an optional trailing :port from a
+// Forwarded for= value, leaving bracketed IPv6 literals untouched.
+func stripForwardedPort(val string) string {
+	if strings.HasPrefix(val, "[") {
+		if i := strings.Index(val, "]"); i != -1 {
+			return val[:i+1]
+		}
+		return val
+	}
+	if host, _, err := net.SplitHostPort(val); err == nil {
+		return host
+	}
+	return val
+}
+
+// URI returns the full request URI, reflecting any Rewrite,
+// RewriteRegex, or TryFiles call made so far. Use OriginalURI for the
+// URI as the client sent it.
+func (c Context) URI() string {
+	return c.Req.RequestURI
+}
+
+// originalURIHeader is an internal request header Rewrite sets, the
+// first time it fires, to the request's URI before the rewrite. It is
+// how OriginalURI recovers that value even though Rewrite overwrites
+// Req.URL/RequestURI in place; a directive that proxies the request
+// upstream should strip it with RemoveRequestHeader first, the same
+// as any other internal bookkeeping header.
+const originalURIHeader = "X-Caddy-Original-Uri"
+
+// OriginalURI returns the request's URI as the client sent it, even
+// after Rewrite, RewriteRegex, or TryFiles has changed URI to reflect
+// a new destination. It equals URI if the request hasn't been
+// rewritten.
+func (c Context) OriginalURI() string {
+	if original := c.Req.Header.Get(originalURIHeader); original != "" {
+		return original
+	}
+	return c.URI()
+}
+
+// Host returns the hostname portion of the Host header, stripping the
+// port.
+func (c Context) Host() (string, error) {
+	host, _, err := net.SplitHostPort(c.Req.Host)
+	if err != nil {
+		return "", err
+	}
+	return host, nil
+}
+
+// Port returns the port portion of the Host header.
+func (c Context) Port() (string, error) {
+	_, port, err := net.SplitHostPort(c.Req.Host)
+	if err != nil {
+		return "", err
+	}
+	return port, nil
+}
+
+// Method returns the HTTP method of the request.
+func (c Context) Method() string {
+	return c.Req.Method
+}
+
+// MethodIs reports whether the request method equals one of methods,
+// compared case-insensitively, so a directive like header or rewrite
+// can be restricted to e.g. {{if .MethodIs "POST" "PUT"}} without the
+// caller having to normalize case itself.
+func (c Context) MethodIs(methods ...string) bool {
+	for _, method := range methods {
+		if strings.EqualFold(c.Req.Method, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnforceAllowedMethods writes a 405 response with an Allow header
+// listing methods and returns true if the current request's method
+// isn't one of them, so a directive restricting a path to e.g.
+// GET/HEAD only (or blocking TRACE everywhere, by passing every other
+// method) can stop the chain immediately with a spec-correct
+// response. It returns false, writing nothing, if the request's
+// method is allowed.
+func (c Context) EnforceAllowedMethods(methods ...string) bool {
+	if c.MethodIs(methods...) {
+		return false
+	}
+	c.ResponseWriter.Header().Set("Allow", strings.Join(methods, ", "))
+	c.ResponseWriter.WriteHeader(http.StatusMethodNotAllowed)
+	return true
+}
+
+// PathMatches returns true if the request path matches pattern. If
+// pattern contains any of the shell glob metacharacters *, ?, or [, it
+// is matched as a glob (** matches across path separators, * and ?
+// don't); otherwise pattern is matched as a plain prefix, as before.
+func (c Context) PathMatches(pattern string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.HasPrefix(c.Req.URL.Path, pattern)
+	}
+
+	re, err := compiledRegexp(globToRegexp(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(c.Req.URL.Path)
+}
+
+// PathMatchesRegex returns true if the request path matches the
+// regular expression pattern. Compiled patterns are cached so that
+// repeated calls with the same pattern don't pay recompilation cost.
+func (c Context) PathMatchesRegex(pattern string) bool {
+	re, err := compiledRegexp(pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(c.Req.URL.Path)
+}
+
+// PathMatchesPattern behaves like PathMatchesRegex, but lets a
+// directive besides Rewrite opt into anchoring (matching the whole
+// path rather than any substring within it) and case-insensitive
+// matching, without callers hand-editing the pattern themselves. The
+// compiled form (pattern plus whichever flags apply) is cached the
+// same way PathMatchesRegex's is.
+func (c Context) PathMatchesPattern(pattern string, anchored, caseInsensitive bool) bool {
+	compiled := pattern
+	if anchored {
+		compiled = "^(?:" + compiled + ")$"
+	}
+	if caseInsensitive {
+		compiled = "(?i)" + compiled
+	}
+
+	re, err := compiledRegexp(compiled)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(c.Req.URL.Path)
+}
+
+// PathHasExtension reports whether the request path's extension is one
+// of extensions (case-insensitive, e.g. ".html"), or, if extensions
+// contains "", whether the path has no extension at all — for a
+// rewrite guard like "map /about to /about.html but leave /image.png
+// alone".
+func (c Context) PathHasExtension(extensions ...string) bool {
+	ext := path.Ext(c.Req.URL.Path)
+	for _, candidate := range extensions {
+		if candidate == "" && ext == "" {
+			return true
+		}
+		if candidate != "" && strings.EqualFold(ext, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// regexpCache holds compiled regular expressions, keyed by their
+// source pattern, shared by PathMatches (for glob-translated patterns)
+// and PathMatchesRegex.
+var regexpCache sync.Map
+
+// compiledRegexp returns the compiled form of pattern, compiling and
+// caching it if this is the first time pattern has been seen.
+func compiledRegexp(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := regexpCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := regexpCache.LoadOrStore(pattern, re)
+	return actual.(*regexp.Regexp), nil
+}
+
+// globToRegexp translates a shell-style glob into an anchored regular
+// expression: "**" becomes ".*" (crosses path separators), "*" becomes
+// "[^/]*", "?" becomes "[^/]", and "[...]" character classes are passed
+// through unchanged. Everything else is treated as a literal.
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end != -1 {
+				b.WriteString(pattern[i : i+end+1])
+				i += end
+				continue
+			}
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"net/http"
+)
+
+// CSRFCookie is the cookie CSRFToken stores the double-submit CSRF
+// token under, and ValidateCSRF reads it back from.
+const CSRFCookie = "caddy_csrf"
+
+// CSRFHeader is the request header ValidateCSRF checks the submitted
+// CSRF token against, in addition to the "csrf_token" form field.
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFToken returns the current request's CSRF token, generating one
+// and storing it in CSRFCookie if it isn't already present, for a
+// template to embed in a form (as a hidden csrf_token field, or an
+// X-CSRF-Token header on an XHR request) so ValidateCSRF can check it
+// on submission. This is the double-submit cookie pattern: the token
+// isn't tied to any server-side session state, so it protects a form
+// even for a client that hasn't authenticated.
+func (c Context) CSRFToken() (string, error) {
+	if token := c.Cookie(CSRFCookie); token != "" {
+		return token, nil
+	}
+
+	token, err := c.RandomString(32, "")
+	if err != nil {
+		return "", err
+	}
+	c.SetCookie(CSRFCookie, token, CookieSameSite(http.SameSiteStrictMode))
+	return token, nil
+}
+
+// ValidateCSRF reports whether the current request satisfies CSRF
+// protection. A request whose method is one of safeMethods (GET,
+// HEAD, and OPTIONS, if safeMethods is empty) always passes, since a
+// safe method isn't supposed to have side effects worth protecting.
+// Any other method must present a token, in the CSRFHeader header or
+// the csrf_token form field, matching CSRFToken's cookie value
+// exactly; a missing cookie or a mismatched/absent submitted token
+// fails validation.
+func (c Context) ValidateCSRF(safeMethods ...string) bool {
+	if len(safeMethods) == 0 {
+		safeMethods = []string{"GET", "HEAD", "OPTIONS"}
+	}
+	if c.MethodIs(safeMethods...) {
+		return true
+	}
+
+	cookieToken := c.Cookie(CSRFCookie)
+	if cookieToken == "" {
+		return false
+	}
+
+	submitted := c.Req.Header.Get(CSRFHeader)
+	if submitted == "" {
+		submitted = c.Req.FormValue("csrf_token")
+	}
+	if submitted == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(cookieToken), []byte(submitted))
+}
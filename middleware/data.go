@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"encoding/csv"
+	"strings"
+)
+
+// CSV reads filename, relative to the site root, as CSV and returns its
+// rows (including the header row, if any) as a slice of string slices,
+// for a template to range over as a data-driven table.
+func (c Context) CSV(filename string) ([][]string, error) {
+	source, err := c.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return csv.NewReader(strings.NewReader(source)).ReadAll()
+}
+
+// YAML reads filename, relative to the site root, as a flat "key: value"
+// document (the same subset front matter parsing already understands)
+// and returns it as a map, for a template to index into as data-driven
+// values such as a price table or team list. Nested structures and
+// lists aren't supported, since parsing them would require a YAML
+// library beyond the standard library.
+func (c Context) YAML(filename string) (map[string]interface{}, error) {
+	source, err := c.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLineFrontMatter(yamlFrontMatterLine)(source), nil
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// RuntimeStats returns a human-readable snapshot of the current
+// process's goroutine count and memory/GC stats, for a debug endpoint
+// gated by IPAllowed (or another auth check) rather than net/http/pprof's
+// default unauthenticated registration on http.DefaultServeMux. Wiring
+// this and net/http/pprof's own handlers behind a configurable
+// directive path needs the routing/directive layer this tree doesn't
+// have.
+func (c Context) RuntimeStats() string {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return fmt.Sprintf(
+		"goroutines: %d\nheap_alloc_bytes: %d\nheap_objects: %d\ngc_cycles: %d\n",
+		runtime.NumGoroutine(), mem.HeapAlloc, mem.HeapObjects, mem.NumGC,
+	)
+}
+
+// GoroutineDump returns a stack trace of every currently running
+// goroutine, the same detail net/http/pprof's goroutine debug endpoint
+// provides, for diagnosing a stuck or leaking handler in production
+// without redeploying.
+func (c Context) GoroutineDump() string {
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	return string(buf[:n])
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DebugCaptureOptions configures CaptureRequestBody and
+// RedactedHeaders, for an opt-in debug logging directive that records
+// request/response detail on selected paths to troubleshoot an API
+// integration without reaching for tcpdump.
+type DebugCaptureOptions struct {
+	// MaxBodyBytes caps how much of a body is captured; the rest is
+	// discarded (from the copy only — the real request body a
+	// handler reads afterward is unaffected). Zero means no limit.
+	MaxBodyBytes int64
+
+	// ContentTypes restricts capture to a body whose Content-Type
+	// starts with one of these (e.g. "application/json"). An empty
+	// list captures any content type.
+	ContentTypes []string
+
+	// RedactHeaders lists header names (case-insensitive) whose
+	// values are replaced with "REDACTED" in RedactedHeaders, for
+	// headers like Authorization or Cookie that shouldn't end up in a
+	// debug log.
+	RedactHeaders []string
+}
+
+// contentTypeAllowed reports whether contentType starts with one of
+// allowed, or true if allowed is empty.
+func contentTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, prefix := range allowed {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CaptureRequestBody returns up to opts.MaxBodyBytes of the current
+// request's body for logging, restoring c.Req.Body so the real
+// handler can still read it afterward, or "" if the request's
+// Content-Type doesn't match opts.ContentTypes.
+func (c Context) CaptureRequestBody(opts DebugCaptureOptions) (string, error) {
+	if !contentTypeAllowed(c.Req.Header.Get("Content-Type"), opts.ContentTypes) {
+		return "", nil
+	}
+
+	body, err := c.readWebhookBody()
+	if err != nil {
+		return "", err
+	}
+	return truncateBody(body, opts.MaxBodyBytes), nil
+}
+
+// truncateBody returns body as a string, capped at maxBytes (or
+// unlimited if maxBytes is zero or negative).
+func truncateBody(body []byte, maxBytes int64) string {
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		body = body[:maxBytes]
+	}
+	return string(body)
+}
+
+// RedactedHeaders returns a copy of header with every name in
+// opts.RedactHeaders replaced by "REDACTED", for logging headers
+// without leaking a credential like Authorization or Cookie.
+func RedactedHeaders(header http.Header, opts DebugCaptureOptions) http.Header {
+	redacted := header.Clone()
+	for _, name := range opts.RedactHeaders {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, "REDACTED")
+		}
+	}
+	return redacted
+}
+
+// ResponseBodyCapturingWriter wraps a ResponseWriterWrapper, buffering
+// up to MaxBodyBytes of the response body for CapturedBody while still
+// writing the full body through to the underlying writer, for a debug
+// logging directive to record what a handler actually sent.
+type ResponseBodyCapturingWriter struct {
+	*ResponseWriterWrapper
+	opts       DebugCaptureOptions
+	captured   []byte
+	statusCode int
+}
+
+// NewResponseBodyCapturingWriter wraps w per opts.
+func NewResponseBodyCapturingWriter(w http.ResponseWriter, opts DebugCaptureOptions) *ResponseBodyCapturingWriter {
+	return &ResponseBodyCapturingWriter{ResponseWriterWrapper: WrapResponseWriter(w), opts: opts}
+}
+
+// WriteHeader records statusCode before delegating to the wrapped
+// writer.
+func (w *ResponseBodyCapturingWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriterWrapper.WriteHeader(statusCode)
+}
+
+// Write appends up to w.opts.MaxBodyBytes of p to the captured buffer,
+// then delegates the full write to the underlying writer.
+func (w *ResponseBodyCapturingWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	if !contentTypeAllowed(w.Header().Get("Content-Type"), w.opts.ContentTypes) {
+		return w.ResponseWriterWrapper.Write(p)
+	}
+	if remaining := w.opts.MaxBodyBytes - int64(len(w.captured)); w.opts.MaxBodyBytes <= 0 || remaining > 0 {
+		chunk := p
+		if w.opts.MaxBodyBytes > 0 && int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		w.captured = append(w.captured, chunk...)
+	}
+	return w.ResponseWriterWrapper.Write(p)
+}
+
+// CapturedBody returns the response body captured so far, capped at
+// w.opts.MaxBodyBytes.
+func (w *ResponseBodyCapturingWriter) CapturedBody() string {
+	return string(w.captured)
+}
+
+// StatusCode returns the status code written so far, defaulting to
+// http.StatusOK if WriteHeader was never called explicitly.
+func (w *ResponseBodyCapturingWriter) StatusCode() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ErrRequestBodyTooLarge is returned by DecompressRequestBody when the
+// decompressed body exceeds maxBytes.
+var ErrRequestBodyTooLarge = errors.New("middleware: decompressed request body exceeds the configured limit")
+
+// DecompressRequestBody reads the request body, transparently
+// decompressing it if Content-Encoding is "gzip" or "deflate", and
+// returns it as a string, so a template handling a POST from a client
+// that compresses its payload (several mobile SDKs do) can work with
+// the plain body via {{(.FromJSON (.DecompressRequestBody 1048576))}}
+// without caring whether the client compressed it. maxBytes caps the
+// decompressed size, returning ErrRequestBodyTooLarge if it's
+// exceeded, since a compressed body can expand far past its wire size.
+// A body with no Content-Encoding (or one this package doesn't
+// recognize) is read as-is.
+func (c Context) DecompressRequestBody(maxBytes int64) (string, error) {
+	var reader io.Reader = c.Req.Body
+
+	switch c.Req.Header.Get("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return "", err
+		}
+		defer gz.Close()
+		reader = gz
+	case "deflate":
+		fl := flate.NewReader(reader)
+		defer fl.Close()
+		reader = fl
+	}
+
+	limited := io.LimitReader(reader, maxBytes+1)
+	body, err := ioutil.ReadAll(limited)
+	if err != nil {
+		return "", err
+	}
+	if int64(len(body)) > maxBytes {
+		return "", fmt.Errorf("%w: %d bytes", ErrRequestBodyTooLarge, maxBytes)
+	}
+	return string(body), nil
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+)
+
+// ErrEgressDenied is returned by HTTPInclude and Webhook when their
+// target URL's host doesn't pass EgressPolicy.
+var ErrEgressDenied = errors.New("middleware: outbound request denied by egress policy")
+
+// EgressPolicy restricts the hosts HTTPInclude and Webhook are allowed
+// to reach, for locked-down networks where those features must be
+// confined to a known set of internal or partner endpoints. Allow and
+// Deny hold shell globs (as accepted by path/filepath.Match) matched
+// against the target URL's hostname; the zero value allows everything,
+// the same "empty allow list means allow all" convention as IPFilter.
+// It doesn't configure an HTTP proxy itself: httpIncludeClient already
+// honors HTTP_PROXY/HTTPS_PROXY/NO_PROXY via the default transport's
+// environment-derived proxy function.
+type EgressPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// allowed reports whether host passes p.
+func (p EgressPolicy) allowed(host string) bool {
+	if matchesAnyHostPattern(host, p.Deny) {
+		return false
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	return matchesAnyHostPattern(host, p.Allow)
+}
+
+// matchesAnyHostPattern reports whether host matches any of patterns.
+func matchesAnyHostPattern(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := filepath.Match(pattern, host); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEgress parses rawurl and returns ErrEgressDenied if its host
+// isn't allowed by policy.
+func checkEgress(policy EgressPolicy, rawurl string) error {
+	target, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	if !policy.allowed(target.Hostname()) {
+		return fmt.Errorf("%w: %s", ErrEgressDenied, target.Hostname())
+	}
+	return nil
+}
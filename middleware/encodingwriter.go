@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// EncodingAwareWriter wraps a ResponseWriterWrapper so byte-range
+// requests, HEAD requests, and Content-Encoding cooperate correctly
+// regardless of which middleware set what, instead of each compressor
+// or range handler needing its own special case against the others:
+//
+//   - If the current request carries a Range header, any
+//     Content-Encoding set by an earlier middleware (e.g. a gzip
+//     writer) is stripped before headers are sent, since a
+//     "bytes=N-M" range refers to the underlying representation, and
+//     nothing in this pipeline can recompute a byte range against an
+//     already-compressed stream.
+//   - Content-Length is dropped whenever Content-Encoding survives to
+//     WriteHeader, since the encoded length isn't the one a handler
+//     computed from the uncompressed body.
+//   - Vary is merged rather than duplicated across multiple
+//     AddHeader("Vary", ...) calls from independent middleware (e.g.
+//     NegotiateImage's "Accept" alongside a compressor's
+//     "Accept-Encoding").
+//   - For a HEAD request, the body a handler writes is discarded
+//     after WriteHeader, so a handler that unconditionally writes a
+//     body doesn't leak one onto the wire, while the Content-Length it
+//     set is preserved.
+type EncodingAwareWriter struct {
+	*ResponseWriterWrapper
+	isRange   bool
+	isHead    bool
+	wroteHead bool
+}
+
+// NewEncodingAwareWriter wraps w, inspecting req for the Range header
+// and HEAD method EncodingAwareWriter needs to enforce at write time.
+func NewEncodingAwareWriter(w http.ResponseWriter, req *http.Request) *EncodingAwareWriter {
+	return &EncodingAwareWriter{
+		ResponseWriterWrapper: WrapResponseWriter(w),
+		isRange:               req.Header.Get("Range") != "",
+		isHead:                req.Method == http.MethodHead,
+	}
+}
+
+// WrapForEncoding returns c.ResponseWriter wrapped in an
+// EncodingAwareWriter for c.Req, for a directive to install ahead of
+// any compression or range handling so those compose correctly.
+func (c Context) WrapForEncoding() *EncodingAwareWriter {
+	return NewEncodingAwareWriter(c.ResponseWriter, c.Req)
+}
+
+// WriteHeader reconciles Content-Encoding/Content-Length/Vary against
+// w.isRange before delegating to the wrapped writer.
+func (w *EncodingAwareWriter) WriteHeader(status int) {
+	if w.wroteHead {
+		return
+	}
+	w.wroteHead = true
+
+	header := w.Header()
+	hadEncoding := header.Get("Content-Encoding") != ""
+	if w.isRange {
+		header.Del("Content-Encoding")
+	}
+	if hadEncoding {
+		header.Del("Content-Length")
+	}
+	if vary := header.Values("Vary"); len(vary) > 1 {
+		header.Set("Vary", mergeVaryValues(vary))
+	}
+
+	w.ResponseWriterWrapper.WriteHeader(status)
+}
+
+// Write delegates to the wrapped writer, discarding the body (while
+// still reporting it as fully written) if the current request is
+// HEAD.
+func (w *EncodingAwareWriter) Write(p []byte) (int, error) {
+	if !w.wroteHead {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.isHead {
+		return len(p), nil
+	}
+	return w.ResponseWriterWrapper.Write(p)
+}
+
+// mergeVaryValues merges the comma-separated Vary values accumulated
+// from independent AddHeader("Vary", ...) calls into one deduplicated,
+// order-preserving list.
+func mergeVaryValues(values []string) string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" || seen[strings.ToLower(part)] {
+				continue
+			}
+			seen[strings.ToLower(part)] = true
+			merged = append(merged, part)
+		}
+	}
+	return strings.Join(merged, ", ")
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import "fmt"
+
+// HandlerError is a rich error carrying the HTTP status code a
+// middleware wants written, a Message safe to show the visitor, and
+// the underlying Err (e.g. a file-not-found or upstream failure) for
+// logging. It implements error via Error, which returns Message, and
+// Unwrap, so callers can still errors.Is/errors.As through to Err.
+type HandlerError struct {
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+// Error returns e.Message, falling back to e.Err's message if Message
+// is empty.
+func (e *HandlerError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("middleware: error with status %d", e.StatusCode)
+}
+
+// Unwrap returns e.Err, so errors.Is and errors.As can see through a
+// HandlerError to its cause.
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// NewHandlerError builds a HandlerError carrying statusCode, the
+// sanitized message to show the visitor, and cause (which may be nil)
+// for logging, so a handler doesn't have to build the struct literal
+// by hand at every error site.
+func NewHandlerError(statusCode int, cause error, message string) *HandlerError {
+	return &HandlerError{StatusCode: statusCode, Message: message, Err: cause}
+}
+
+// LogDetail returns a string combining e.StatusCode with e.Err's
+// message, for the errors middleware to write to its log while
+// showing the visitor e.Error()'s sanitized Message instead, so a
+// cause like an upstream's raw error text never reaches a response
+// body. It falls back to e.Error() if e.Err is nil.
+func (e *HandlerError) LogDetail() string {
+	if e.Err == nil {
+		return e.Error()
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Err.Error())
+}
+
+// ErrorPage renders the most specific error page template available
+// for statusCode, trying "<code>.html" (e.g. "404.html"), then the
+// wildcard class "<class>xx.html" (e.g. "4xx.html"), then, for the 5xx
+// class only, the conventional catch-all "50x.html", then "error.html",
+// in that order. Whichever template is used is executed as a Go
+// template with c as its data plus statusCode as {{index .Args 0}}, so
+// it can show the status alongside anything else c exposes, such as
+// {{.OriginalURI}} for the path that failed and {{.TraceID}} for a
+// request ID to reference in a support ticket.
+func (c Context) ErrorPage(statusCode int) (string, error) {
+	var lastErr error
+	for _, candidate := range errorPageCandidates(statusCode) {
+		content, err := c.Include(candidate, statusCode)
+		if err == nil {
+			return content, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// ErrorPageFor is a convenience for ErrorPage(err.StatusCode), for a
+// handler that already has a *HandlerError from further down the
+// chain.
+func (c Context) ErrorPageFor(err *HandlerError) (string, error) {
+	return c.ErrorPage(err.StatusCode)
+}
+
+// errorPageCandidates lists the error page filenames to try, from most
+// to least specific, for statusCode.
+func errorPageCandidates(statusCode int) []string {
+	candidates := []string{
+		fmt.Sprintf("%d.html", statusCode),
+		fmt.Sprintf("%dxx.html", statusCode/100),
+	}
+	if statusCode/100 == 5 {
+		candidates = append(candidates, "50x.html")
+	}
+	return append(candidates, "error.html")
+}
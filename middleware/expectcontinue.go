@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Expect100ContinueDecision controls how RespondToExpectContinue
+// handles a request carrying "Expect: 100-continue".
+type Expect100ContinueDecision int
+
+const (
+	// Expect100ContinueSend sends the 100 Continue intermediate
+	// response, telling the client to go ahead and transfer its body.
+	Expect100ContinueSend Expect100ContinueDecision = iota
+	// Expect100ContinueReject sends the final response immediately
+	// instead, so the client never transfers a body that would be
+	// denied anyway.
+	Expect100ContinueReject
+)
+
+// RespondToExpectContinue inspects the request for "Expect:
+// 100-continue" and, if present, either sends the 100 Continue
+// intermediate response (Expect100ContinueSend) or short-circuits
+// with rejectStatus (Expect100ContinueReject, e.g. 401 or 429)
+// without reading the body. A directive runs its own auth/ratelimit
+// checks first — before touching c.Req.Body — and only calls this
+// once it knows which way to go; that ordering, not this function, is
+// what makes a large upload get rejected before it's transferred. It
+// reports whether Expect: 100-continue was present and it took
+// action; a false return means the request didn't ask for
+// 100-continue, and the caller should proceed normally without
+// calling WriteHeader itself.
+func (c Context) RespondToExpectContinue(decision Expect100ContinueDecision, rejectStatus int) bool {
+	if !strings.EqualFold(c.Req.Header.Get("Expect"), "100-continue") {
+		return false
+	}
+
+	if decision == Expect100ContinueReject {
+		c.ResponseWriter.WriteHeader(rejectStatus)
+	} else {
+		c.ResponseWriter.WriteHeader(http.StatusContinue)
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"expvar"
+	"sync"
+)
+
+var (
+	expvarCountersMu sync.Mutex
+	expvarCounters   = make(map[string]*expvar.Int)
+
+	expvarGaugesMu sync.Mutex
+	expvarGauges   = make(map[string]*expvar.Float)
+)
+
+// IncrementCounter increments (publishing it first if this is the
+// first call for name) a process-wide expvar.Int named name, so a
+// directive can track e.g. "requests_per_vhost" or "cache_hits"
+// without managing its own expvar.Publish bookkeeping. Importing
+// "expvar" registers its /debug/vars handler on http.DefaultServeMux
+// automatically; exposing that at a directive-configured path still
+// needs the routing layer this tree doesn't have, so the counters
+// published here are reachable through expvar's own mechanism, not a
+// directive of this package's.
+func IncrementCounter(name string) {
+	expvarCountersMu.Lock()
+	defer expvarCountersMu.Unlock()
+
+	counter, ok := expvarCounters[name]
+	if !ok {
+		counter = expvar.NewInt(name)
+		expvarCounters[name] = counter
+	}
+	counter.Add(1)
+}
+
+// SetGauge publishes value under name as an expvar.Float (publishing
+// it first if this is the first call for name), overwriting any
+// previous value, the gauge counterpart to IncrementCounter for a
+// value like "upstream_failures" that can go back down.
+func SetGauge(name string, value float64) {
+	expvarGaugesMu.Lock()
+	defer expvarGaugesMu.Unlock()
+
+	gauge, ok := expvarGauges[name]
+	if !ok {
+		gauge = expvar.NewFloat(name)
+		expvarGauges[name] = gauge
+	}
+	gauge.Set(value)
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchesExtension reports whether filename's extension is one of
+// extensions, compared case-insensitively. It is meant for a Templates
+// directive to decide which requests should be executed as templates
+// rather than served as static files.
+func MatchesExtension(filename string, extensions []string) bool {
+	ext := path.Ext(filename)
+	for _, candidate := range extensions {
+		if strings.EqualFold(ext, candidate) {
+			return true
+		}
+	}
+	return false
+}
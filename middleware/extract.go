@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ErrArchiveEscapesRoot is returned by ExtractZipUpload when an
+// archive entry's name would resolve outside destDir once joined and
+// cleaned, the "zip slip" attack (an entry named e.g.
+// "../../etc/passwd").
+var ErrArchiveEscapesRoot = errors.New("middleware: archive entry escapes the extraction directory")
+
+// ErrArchiveTooLarge is returned by ExtractZipUpload once the
+// cumulative uncompressed size of the entries written exceeds the
+// maxBytes it was called with.
+var ErrArchiveTooLarge = errors.New("middleware: archive exceeds the configured size limit")
+
+// ExtractZipUpload reads the multipart file field named fieldName (an
+// uploaded .zip archive) and extracts its entries into destDir,
+// relative to the site root, completing the upload/browse
+// file-manager story WriteZip's archive download started. Each
+// entry's destination is clamped under destDir with joinUnderRoot and
+// verified with withinRoot, so a crafted entry name can't write
+// outside it. Extraction stops with ErrArchiveTooLarge as soon as the
+// cumulative bytes actually decompressed would exceed maxBytes (0
+// means unlimited); the limit is enforced against real output, not an
+// entry's self-reported uncompressed size, so an entry lying about its
+// size in the header can't decompress past it. Whatever was already
+// written for prior entries is left in place. It returns the number
+// of files written.
+func (c Context) ExtractZipUpload(fieldName, destDir string, maxBytes int64) (int, error) {
+	dir, ok := c.Root.(http.Dir)
+	if !ok {
+		return 0, ErrUploadRootNotWritable
+	}
+
+	file, header, err := c.Req.FormFile(fieldName)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		return 0, err
+	}
+
+	destRoot := joinUnderRoot(dir, destDir)
+	if err := os.MkdirAll(destRoot, c.dirMode()); err != nil {
+		return 0, err
+	}
+
+	var written, total int64
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+
+		entryPath := joinUnderRoot(dir, path.Join(destDir, entry.Name))
+		if !withinRoot(destRoot, entryPath) {
+			return int(written), fmt.Errorf("%w: %s", ErrArchiveEscapesRoot, entry.Name)
+		}
+
+		limit := int64(-1)
+		if maxBytes > 0 {
+			limit = maxBytes - total
+			if limit <= 0 {
+				return int(written), ErrArchiveTooLarge
+			}
+		}
+
+		n, err := extractZipEntry(entry, entryPath, c.dirMode(), c.fileMode(), limit)
+		total += n
+		if err != nil {
+			return int(written), err
+		}
+
+		written++
+	}
+
+	return int(written), nil
+}
+
+// extractZipEntry writes the contents of entry to destPath, creating
+// its parent directory first, and returns the number of bytes
+// actually decompressed. limit, if >= 0, caps how many bytes are
+// written regardless of what entry's header claims about its
+// uncompressed size, by reading one byte past limit and failing with
+// ErrArchiveTooLarge if the source had more to give; a negative limit
+// means unlimited. A partially written destPath is removed on any
+// error, decompression bomb or otherwise, so a failed extraction
+// never leaves truncated output behind.
+func extractZipEntry(entry *zip.File, destPath string, dirMode, fileMode os.FileMode, limit int64) (n int64, err error) {
+	if err := os.MkdirAll(filepath.Dir(destPath), dirMode); err != nil {
+		return 0, err
+	}
+
+	src, err := entry.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	defer func() {
+		if err != nil {
+			os.Remove(destPath)
+		}
+	}()
+
+	reader := io.Reader(src)
+	if limit >= 0 {
+		reader = io.LimitReader(src, limit+1)
+	}
+
+	n, err = io.Copy(dst, reader)
+	if err != nil {
+		return n, err
+	}
+	if limit >= 0 && n > limit {
+		return n, ErrArchiveTooLarge
+	}
+	return n, nil
+}
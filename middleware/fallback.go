@@ -0,0 +1,20 @@
+package middleware
+
+import "net/http"
+
+// FirstExistingRoot returns the first of roots that has a file at name,
+// along with true, for a file server directive that falls back across
+// multiple site roots (e.g. a themeable root layered over a shared
+// default) before returning a 404. It returns the zero http.Dir and
+// false if name doesn't exist under any of roots.
+func FirstExistingRoot(name string, roots ...http.Dir) (http.Dir, bool) {
+	for _, root := range roots {
+		f, err := root.Open(name)
+		if err != nil {
+			continue
+		}
+		f.Close()
+		return root, true
+	}
+	return "", false
+}
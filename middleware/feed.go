@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"html"
+	"path"
+	"strings"
+)
+
+// Feed renders an RSS 2.0 feed for the Markdown pages in dirName, in
+// the same order as Archive(dirName, wordCount), using each page's
+// front matter "title" (falling back to its filename) and "date", and
+// its excerpt as the item description. baseURL is used both as the
+// channel link and to build each item's absolute link.
+func (c Context) Feed(dirName, baseURL, title string, wordCount int) (string, error) {
+	entries, err := c.Archive(dirName, wordCount)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<rss version="2.0"><channel>` + "\n")
+	b.WriteString("  <title>" + html.EscapeString(title) + "</title>\n")
+	b.WriteString("  <link>" + html.EscapeString(baseURL) + "</link>\n")
+
+	for _, entry := range entries {
+		itemTitle, _ := entry.Metadata["title"].(string)
+		if itemTitle == "" {
+			itemTitle = entry.Name
+		}
+
+		link := baseURL + "/" + strings.TrimPrefix(path.Join(dirName, entry.Name), "./")
+
+		b.WriteString("  <item>\n")
+		b.WriteString("    <title>" + html.EscapeString(itemTitle) + "</title>\n")
+		b.WriteString("    <link>" + html.EscapeString(link) + "</link>\n")
+		b.WriteString("    <guid>" + html.EscapeString(link) + "</guid>\n")
+		if date := archiveDate(entry); date != "" {
+			b.WriteString("    <pubDate>" + html.EscapeString(date) + "</pubDate>\n")
+		}
+		b.WriteString("    <description>" + html.EscapeString(entry.Excerpt) + "</description>\n")
+		b.WriteString("  </item>\n")
+	}
+
+	b.WriteString("</channel></rss>\n")
+	return b.String(), nil
+}
+
+// AtomFeed renders an Atom 1.0 feed for the Markdown pages in dirName,
+// the same way Feed renders RSS 2.0: same entry order, front matter
+// fields, and excerpt-as-summary, for a site that wants to publish
+// both feed formats or prefers Atom's stricter, better-specified
+// syntax.
+func (c Context) AtomFeed(dirName, baseURL, title string, wordCount int) (string, error) {
+	entries, err := c.Archive(dirName, wordCount)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<feed xmlns="http://www.w3.org/2005/Atom">` + "\n")
+	b.WriteString("  <title>" + html.EscapeString(title) + "</title>\n")
+	b.WriteString(`  <link href="` + html.EscapeString(baseURL) + `"/>` + "\n")
+	b.WriteString("  <id>" + html.EscapeString(baseURL) + "</id>\n")
+
+	for _, entry := range entries {
+		itemTitle, _ := entry.Metadata["title"].(string)
+		if itemTitle == "" {
+			itemTitle = entry.Name
+		}
+
+		link := baseURL + "/" + strings.TrimPrefix(path.Join(dirName, entry.Name), "./")
+
+		b.WriteString("  <entry>\n")
+		b.WriteString("    <title>" + html.EscapeString(itemTitle) + "</title>\n")
+		b.WriteString(`    <link href="` + html.EscapeString(link) + `"/>` + "\n")
+		b.WriteString("    <id>" + html.EscapeString(link) + "</id>\n")
+		if date := archiveDate(entry); date != "" {
+			b.WriteString("    <updated>" + html.EscapeString(date) + "</updated>\n")
+		}
+		b.WriteString("    <summary>" + html.EscapeString(entry.Excerpt) + "</summary>\n")
+		b.WriteString("  </entry>\n")
+	}
+
+	b.WriteString("</feed>\n")
+	return b.String(), nil
+}
@@ -0,0 +1,41 @@
+package middleware
+
+// GeoIPRecord holds the location data GeoIPProvider.Lookup resolves
+// for an IP address.
+type GeoIPRecord struct {
+	Country string
+	Region  string
+}
+
+// GeoIPProvider resolves an IP address to a GeoIPRecord, so
+// Context.GeoIP can be backed by whatever database format an operator
+// has available (e.g. a MaxMind GeoLite2 reader) without this package
+// depending on one directly. It returns ok=false if ip isn't found in
+// the underlying database.
+type GeoIPProvider interface {
+	Lookup(ip string) (record GeoIPRecord, ok bool)
+}
+
+// geoIPProvider is the GeoIPProvider GeoIP looks up through, set once
+// at startup via SetGeoIPProvider. It is nil until then, since this
+// package ships no concrete implementation: a MaxMind DB reader (or
+// any other provider) needs a database-parsing dependency outside the
+// standard library.
+var geoIPProvider GeoIPProvider
+
+// SetGeoIPProvider sets the GeoIPProvider used by GeoIP for the
+// process. Passing nil disables lookups again.
+func SetGeoIPProvider(provider GeoIPProvider) {
+	geoIPProvider = provider
+}
+
+// GeoIP returns the GeoIPRecord for the current request's ClientIP, as
+// resolved by the GeoIPProvider set with SetGeoIPProvider, and whether
+// a record was found. It always returns ok=false if no provider has
+// been set.
+func (c Context) GeoIP() (GeoIPRecord, bool) {
+	if geoIPProvider == nil {
+		return GeoIPRecord{}, false
+	}
+	return geoIPProvider.Lookup(c.ClientIP())
+}
@@ -0,0 +1,45 @@
+package middleware
+
+// UpstreamHealth is one upstream's readiness detail, keyed by name in
+// a ReadinessReport's detail map.
+type UpstreamHealth struct {
+	Ready  bool   `json:"ready"`
+	Status string `json:"status"`
+}
+
+// ReadinessReport reflects whether the upstreams tracked by breaker
+// (keyed the same way CircuitAllowed keys them) are currently healthy,
+// for a `health` directive's readiness endpoint's optional JSON
+// detail. It only reports breaker's last recorded status via Status,
+// never issuing a probe itself the way Allow can. Whether listeners
+// are bound and certificates are valid reflect server/TLS state this
+// tree doesn't have, so a readiness endpoint here can only be as
+// complete as upstream health.
+func ReadinessReport(breaker *CircuitBreaker, keys []string) (ready bool, detail map[string]UpstreamHealth) {
+	detail = make(map[string]UpstreamHealth, len(keys))
+	ready = true
+
+	for _, key := range keys {
+		status := breaker.Status(key)
+		healthy := status != CircuitOpen
+		if !healthy {
+			ready = false
+		}
+		detail[key] = UpstreamHealth{Ready: healthy, Status: circuitStatusName(status)}
+	}
+	return ready, detail
+}
+
+// circuitStatusName returns status's lowercase, JSON-friendly name.
+func circuitStatusName(status CircuitBreakerStatus) string {
+	switch status {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// highlightKeywords lists the reserved words SyntaxHighlight wraps in
+// a "hl-kw" span for each language it recognizes. Only a handful of
+// common languages are covered; an unrecognized lang falls back to
+// escaped, unstyled code, since this package has no general tokenizer
+// to fall back on.
+var highlightKeywords = map[string]map[string]bool{
+	"go":         wordSet("func", "package", "import", "return", "if", "else", "for", "range", "var", "const", "type", "struct", "interface", "defer", "go", "chan", "select", "switch", "case", "break", "continue", "nil", "true", "false"),
+	"javascript": wordSet("function", "return", "if", "else", "for", "while", "var", "let", "const", "class", "import", "export", "new", "this", "null", "true", "false", "typeof"),
+	"python":     wordSet("def", "return", "if", "elif", "else", "for", "while", "import", "from", "class", "with", "as", "try", "except", "finally", "lambda", "None", "True", "False"),
+}
+
+// wordSet builds a set from words, for highlightKeywords.
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// highlightToken matches, in priority order, a line comment ("//" or
+// "#" to end of line), a single- or double-quoted string, or a bare
+// word, for SyntaxHighlight to classify one token at a time.
+var highlightToken = regexp.MustCompile(`(//.*$|#.*$)|("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')|([A-Za-z_][A-Za-z0-9_]*)`)
+
+// SyntaxHighlight wraps a fenced code block's comments, string
+// literals, and lang's reserved words in "hl-com", "hl-str", and
+// "hl-kw" spans respectively (each also gets an "hl-<styleName>" class
+// if styleName is set, so a stylesheet can theme multiple highlight
+// styles off the same markup), letting a template render colorized
+// code without a client-side highlighter. It processes each line
+// independently with regexes rather than a real parser, so it doesn't
+// understand multi-line strings or comments, and lang is matched
+// case-insensitively against the small set of languages
+// highlightKeywords covers; anything else is returned escaped but
+// unstyled.
+func SyntaxHighlight(code, lang, styleName string) string {
+	keywords := highlightKeywords[strings.ToLower(lang)]
+
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		lines[i] = highlightLine(line, keywords, styleName)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// highlightLine tokenizes and wraps one line of code, as SyntaxHighlight
+// documents.
+func highlightLine(line string, keywords map[string]bool, styleName string) string {
+	var out strings.Builder
+
+	last := 0
+	for _, m := range highlightToken.FindAllStringSubmatchIndex(line, -1) {
+		out.WriteString(html.EscapeString(line[last:m[0]]))
+		last = m[1]
+
+		switch {
+		case m[2] != -1:
+			out.WriteString(highlightSpan("com", styleName, line[m[2]:m[3]]))
+		case m[4] != -1:
+			out.WriteString(highlightSpan("str", styleName, line[m[4]:m[5]]))
+		case m[6] != -1:
+			word := line[m[6]:m[7]]
+			if keywords[word] {
+				out.WriteString(highlightSpan("kw", styleName, word))
+			} else {
+				out.WriteString(html.EscapeString(word))
+			}
+		}
+	}
+	out.WriteString(html.EscapeString(line[last:]))
+
+	return out.String()
+}
+
+// highlightSpan wraps text (escaped) in a span classed "hl-<kind>",
+// plus "hl-<styleName>" if styleName is set.
+func highlightSpan(kind, styleName, text string) string {
+	class := "hl-" + kind
+	if styleName != "" {
+		class += " hl-" + styleName
+	}
+	return `<span class="` + class + `">` + html.EscapeString(text) + `</span>`
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// BanList tracks IP addresses banned until a point in time, for a
+// honeypot directive that permanently punishes automated scanners which
+// request a path no legitimate visitor would (e.g. "/wp-login.php" on a
+// site that isn't WordPress). The zero value is ready to use.
+type BanList struct {
+	mu          sync.Mutex
+	bannedUntil map[string]time.Time
+}
+
+// Ban marks ip as banned until expires.
+func (b *BanList) Ban(ip string, expires time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bannedUntil == nil {
+		b.bannedUntil = make(map[string]time.Time)
+	}
+	b.bannedUntil[ip] = expires
+}
+
+// IsBanned reports whether ip is currently banned, as of now.
+func (b *BanList) IsBanned(ip string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	expires, ok := b.bannedUntil[ip]
+	if !ok {
+		return false
+	}
+	if !now.Before(expires) {
+		delete(b.bannedUntil, ip)
+		return false
+	}
+	return true
+}
+
+// CheckHoneypot reports whether the current request's path matches one
+// of paths (see Context.PathMatches for the glob syntax), and if so,
+// bans the request's ClientIP in bans until banFor has elapsed. A
+// directive serving a honeypot path calls this once per request and
+// uses the returned bool to decide whether to log/serve a decoy
+// response.
+func (c Context) CheckHoneypot(bans *BanList, banFor time.Duration, paths ...string) bool {
+	for _, path := range paths {
+		if c.PathMatches(path) {
+			bans.Ban(c.ClientIP(), c.Now().Add(banFor))
+			return true
+		}
+	}
+	return false
+}
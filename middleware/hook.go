@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"time"
+)
+
+// ErrHookTimeout is returned by RunHookCommand when the command does
+// not finish within its timeout.
+var ErrHookTimeout = errors.New("middleware: hook command timed out")
+
+// ErrHookUnauthorized is returned by RunAuthenticatedHookCommand when
+// the triggering request fails signature verification.
+var ErrHookUnauthorized = errors.New("middleware: hook request failed signature verification")
+
+// RunHookCommand runs name with args, waiting at most timeout, and
+// returns its combined stdout and stderr. The caller is responsible
+// for writing the returned output to its own error log, since this
+// package has no logging sink of its own.
+func RunHookCommand(name string, args []string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return output.String(), ErrHookTimeout
+	}
+	return output.String(), err
+}
+
+// RunAuthenticatedHookCommand verifies the request via
+// VerifyHMACSignature and, if it verifies, runs name/args through
+// RunHookCommand, for a `hook`/`on` directive's HTTP-webhook trigger.
+// It returns ErrHookUnauthorized without running the command if
+// verification fails. Running hooks off server lifecycle events
+// (startup, shutdown, certificate renewal, config reload) instead of
+// a request needs the lifecycle infrastructure to fire them from,
+// which this tree doesn't have.
+func (c Context) RunAuthenticatedHookCommand(secret string, maxAge time.Duration, name string, args []string, timeout time.Duration) (string, error) {
+	if !c.VerifyHMACSignature(secret, maxAge) {
+		return "", ErrHookUnauthorized
+	}
+	return RunHookCommand(name, args, timeout)
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import "strings"
+
+// HostMap looks up a per-tenant value (a root directory, a redirect
+// target, or any other placeholder-friendly string) from a
+// WatchedList of "host value" lines, so a `map` directive can serve
+// thousands of tenant domains from one site block and an external
+// table instead of one site block per domain. Fields are as documented
+// on WatchedList: List must be set before calling Lookup, and its
+// backing file is reloaded automatically as it changes.
+type HostMap struct {
+	List *WatchedList
+}
+
+// Lookup returns the value mapped to key (typically Host, but any
+// placeholder value works) and true, or "" and false if key isn't
+// mapped or List is nil. Each entry line is "key value", split on the
+// first run of whitespace; malformed lines (no value) are ignored.
+func (m HostMap) Lookup(key string) (string, bool) {
+	if m.List == nil {
+		return "", false
+	}
+	for _, entry := range m.List.Entries() {
+		k, v, ok := splitMapEntry(entry)
+		if ok && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// splitMapEntry splits a "key value" HostMap entry on its first run of
+// whitespace, reporting false if entry has no value.
+func splitMapEntry(entry string) (key, value string, ok bool) {
+	fields := strings.Fields(entry)
+	if len(fields) < 2 {
+		return "", "", false
+	}
+	return fields[0], strings.Join(fields[1:], " "), true
+}
+
+// MapHost looks up key in table, for a template to resolve the
+// current tenant's root directory or redirect target from the request
+// Host (or any other placeholder value). It returns "" if key isn't
+// mapped.
+func (c Context) MapHost(table HostMap, key string) string {
+	value, _ := table.Lookup(key)
+	return value
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// hostnameCacheEntry holds a cached reverse DNS lookup result together
+// with when it expires.
+type hostnameCacheEntry struct {
+	hostname string
+	err      error
+	expires  time.Time
+}
+
+// HostnameCache caches reverse DNS (PTR) lookups for a TTL, so
+// Context.ClientHostname doesn't hammer the resolver for repeat visitors from
+// the same address. The zero value is ready to use.
+type HostnameCache struct {
+	mu      sync.Mutex
+	entries map[string]hostnameCacheEntry
+	lookup  func(ip string) ([]string, error)
+}
+
+// get returns the cached hostname for ip, performing (and caching) a
+// fresh lookup if there's no unexpired entry.
+func (h *HostnameCache) get(ip string, ttl time.Time, cacheFor time.Duration) (string, error) {
+	h.mu.Lock()
+	if h.entries == nil {
+		h.entries = make(map[string]hostnameCacheEntry)
+	}
+	if entry, ok := h.entries[ip]; ok && ttl.Before(entry.expires) {
+		h.mu.Unlock()
+		return entry.hostname, entry.err
+	}
+	h.mu.Unlock()
+
+	lookup := h.lookup
+	if lookup == nil {
+		lookup = net.LookupAddr
+	}
+	names, err := lookup(ip)
+
+	var hostname string
+	if err == nil && len(names) > 0 {
+		hostname = names[0]
+	}
+
+	h.mu.Lock()
+	h.entries[ip] = hostnameCacheEntry{hostname: hostname, err: err, expires: ttl.Add(cacheFor)}
+	h.mu.Unlock()
+
+	return hostname, err
+}
+
+// ClientHostname performs a reverse DNS lookup of the current request's
+// ClientIP, caching the result in cache for cacheFor so that templates
+// and logging that display hostnames instead of raw IPs don't trigger
+// a fresh lookup on every request from the same address. Not to be
+// confused with Hostname, which reports the local machine's own name.
+func (c Context) ClientHostname(cache *HostnameCache, cacheFor time.Duration) (string, error) {
+	return cache.get(c.ClientIP(), c.Now(), cacheFor)
+}
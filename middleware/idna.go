@@ -0,0 +1,269 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+)
+
+// Punycode (RFC 3492) parameters.
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+	punycodeACEPrefix   = "xn--"
+)
+
+// ErrPunycodeOverflow is returned by the punycode encoder/decoder when
+// a label is too large or malformed to represent.
+var ErrPunycodeOverflow = errors.New("middleware: punycode overflow or malformed label")
+
+// ToASCIIHost converts host's internationalized labels (e.g.
+// "bücher.example") to their ASCII-compatible punycode form (e.g.
+// "xn--bcher-kva.example"), leaving already-ASCII labels untouched, so
+// a site configured with a Unicode label matches what actually
+// arrives on the wire and gets looked up in a cert store keyed by its
+// ACE form. Ports, if present, are preserved as-is.
+func ToASCIIHost(host string) (string, error) {
+	hostPart, port := splitHostPort(host)
+	labels := strings.Split(hostPart, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := punycodeEncodeLabel(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = punycodeACEPrefix + encoded
+	}
+	return joinHostPort(strings.Join(labels, "."), port), nil
+}
+
+// ToUnicodeHost converts host's punycode ("xn--...") labels back to
+// Unicode (e.g. "xn--bcher-kva.example" to "bücher.example"), leaving
+// any label that isn't in ACE form untouched. Ports, if present, are
+// preserved as-is.
+func ToUnicodeHost(host string) (string, error) {
+	hostPart, port := splitHostPort(host)
+	labels := strings.Split(hostPart, ".")
+	for i, label := range labels {
+		if !strings.HasPrefix(strings.ToLower(label), punycodeACEPrefix) {
+			continue
+		}
+		decoded, err := punycodeDecodeLabel(label[len(punycodeACEPrefix):])
+		if err != nil {
+			return "", err
+		}
+		labels[i] = decoded
+	}
+	return joinHostPort(strings.Join(labels, "."), port), nil
+}
+
+// HostMatchesIDN reports whether the current request's Host, after
+// normalizing both sides to ASCII/punycode form, matches pattern
+// (which may be given in either Unicode or ACE form), for Host
+// matching that treats "bücher.example" and "xn--bcher-kva.example"
+// as the same site.
+func (c Context) HostMatchesIDN(pattern string) bool {
+	requestHost, err := ToASCIIHost(c.Req.Host)
+	if err != nil {
+		return false
+	}
+	patternHost, err := ToASCIIHost(pattern)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(requestHost, patternHost)
+}
+
+// splitHostPort splits host into its host and port parts on the last
+// colon, returning an empty port if there isn't one. Unlike
+// net.SplitHostPort, it doesn't error on a bare host with no port,
+// since ToASCIIHost/ToUnicodeHost need to accept both a Host header
+// (which may or may not carry a port) and a bare site label. Domain
+// labels never contain a colon, so this is unambiguous for the
+// hostnames these functions deal with.
+func splitHostPort(host string) (hostPart, port string) {
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		return host[:i], host[i:]
+	}
+	return host, ""
+}
+
+// joinHostPort reassembles what splitHostPort split.
+func joinHostPort(hostPart, port string) string {
+	return hostPart + port
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// punycodeEncodeLabel encodes label (which must contain at least one
+// non-ASCII rune) into the part of a punycode string that follows the
+// "xn--" prefix, per RFC 3492.
+func punycodeEncodeLabel(label string) (string, error) {
+	input := []rune(label)
+
+	var basic []rune
+	for _, r := range input {
+		if r < 0x80 {
+			basic = append(basic, r)
+		}
+	}
+
+	n := punycodeInitialN
+	delta := 0
+	bias := punycodeInitialBias
+	b := len(basic)
+	h := b
+
+	var out strings.Builder
+	out.WriteString(string(basic))
+	if b > 0 {
+		out.WriteByte('-')
+	}
+
+	for h < len(input) {
+		m := -1
+		for _, r := range input {
+			if int(r) >= n && (m == -1 || int(r) < m) {
+				m = int(r)
+			}
+		}
+		if m == -1 {
+			return "", ErrPunycodeOverflow
+		}
+
+		delta += (m - n) * (h + 1)
+		n = m
+
+		for _, r := range input {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) == n {
+				q := delta
+				for k := punycodeBase; ; k += punycodeBase {
+					t := punycodeThreshold(k, bias)
+					if q < t {
+						break
+					}
+					out.WriteByte(punycodeDigitToBasic(t + (q-t)%(punycodeBase-t)))
+					q = (q - t) / (punycodeBase - t)
+				}
+				out.WriteByte(punycodeDigitToBasic(q))
+				bias = punycodeAdapt(delta, h+1, h == b)
+				delta = 0
+				h++
+			}
+		}
+		delta++
+		n++
+	}
+
+	return out.String(), nil
+}
+
+// punycodeDecodeLabel decodes suffix (the part of a punycode string
+// after the "xn--" prefix) back to Unicode, per RFC 3492.
+func punycodeDecodeLabel(suffix string) (string, error) {
+	n := punycodeInitialN
+	i := 0
+	bias := punycodeInitialBias
+
+	delim := strings.LastIndexByte(suffix, '-')
+	var output []rune
+	pos := 0
+	if delim >= 0 {
+		output = []rune(suffix[:delim])
+		pos = delim + 1
+	}
+
+	for pos < len(suffix) {
+		oldi := i
+		w := 1
+		for k := punycodeBase; ; k += punycodeBase {
+			if pos >= len(suffix) {
+				return "", ErrPunycodeOverflow
+			}
+			digit, err := punycodeBasicToDigit(suffix[pos])
+			if err != nil {
+				return "", err
+			}
+			pos++
+			i += digit * w
+			t := punycodeThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punycodeBase - t
+		}
+		bias = punycodeAdapt(i-oldi, len(output)+1, oldi == 0)
+		n += i / (len(output) + 1)
+		i = i % (len(output) + 1)
+
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+
+	return string(output), nil
+}
+
+func punycodeThreshold(k, bias int) int {
+	switch {
+	case k <= bias+punycodeTMin:
+		return punycodeTMin
+	case k >= bias+punycodeTMax:
+		return punycodeTMax
+	default:
+		return k - bias
+	}
+}
+
+func punycodeAdapt(delta, numPoints int, firstTime bool) int {
+	if firstTime {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + ((punycodeBase-punycodeTMin+1)*delta)/(delta+punycodeSkew)
+}
+
+func punycodeDigitToBasic(digit int) byte {
+	if digit < 26 {
+		return byte('a' + digit)
+	}
+	return byte('0' + digit - 26)
+}
+
+func punycodeBasicToDigit(c byte) (int, error) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), nil
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A'), nil
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26, nil
+	default:
+		return 0, ErrPunycodeOverflow
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import "net"
+
+// IPFilter allow/deny-lists client addresses by CIDR range, for an
+// `ipfilter` directive. The zero value denies nothing (an empty allow
+// list is treated as "allow all" the same way an empty deny list is
+// "deny nothing").
+type IPFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// Allowed reports whether ip is permitted by f: denied if it matches
+// any of f.Deny, then allowed if f.Allow is empty or ip matches one of
+// its entries, denied otherwise. Malformed CIDR entries never match,
+// rather than making the whole filter error out.
+func (f IPFilter) Allowed(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	if matchesAnyCIDR(addr, f.Deny) {
+		return false
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	return matchesAnyCIDR(addr, f.Allow)
+}
+
+// matchesAnyCIDR reports whether addr falls within any of cidrs.
+func matchesAnyCIDR(addr net.IP, cidrs []string) bool {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPAllowed reports whether the current request's ClientIP is
+// permitted by filter, for a directive to decide whether to serve the
+// request or respond with a block (e.g. 403).
+func (c Context) IPAllowed(filter IPFilter) bool {
+	return filter.Allowed(c.ClientIP())
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// DisableKeepAlive sets a Connection: close header on the current
+// response, telling the client (and the server, once it writes this
+// response) to close the connection rather than keep it alive for
+// reuse. Combine it with PathMatches or ClientIP to disable keep-alive
+// only for certain paths or clients, the way AnnounceDraining composes
+// it with drain state.
+func (c Context) DisableKeepAlive() {
+	c.ResponseWriter.Header().Set("Connection", "close")
+}
+
+// keepAliveSweepInterval bounds how often LimitRequestsPerConnection
+// walks the whole counts map looking for stale entries, so the sweep
+// itself doesn't turn every call into an O(map size) operation.
+const keepAliveSweepInterval = time.Minute
+
+// keepAliveIdleTTL is how long a connection's tracked count may sit
+// untouched before it's assumed the underlying TCP connection already
+// closed without ever reaching maxRequests. It's well beyond any
+// realistic keep-alive idle timeout.
+const keepAliveIdleTTL = 10 * time.Minute
+
+// keepAliveConnState tracks one connection's request count and when it
+// was last seen.
+type keepAliveConnState struct {
+	count    int
+	lastSeen time.Time
+}
+
+// KeepAliveLimiter tracks how many requests have been served over each
+// kept-alive connection, so one can be recycled after a configured
+// maximum instead of serving requests over it indefinitely. Most
+// connections never reach that maximum before the client or a
+// listener-level idle timeout closes them; those entries are pruned
+// automatically once they go quiet, rather than sitting in the map
+// forever. The zero value is not usable; construct one with
+// NewKeepAliveLimiter.
+type KeepAliveLimiter struct {
+	mu     sync.Mutex
+	counts map[string]*keepAliveConnState
+	sweep  sweepGate
+}
+
+// NewKeepAliveLimiter returns a ready-to-use KeepAliveLimiter.
+func NewKeepAliveLimiter() *KeepAliveLimiter {
+	return &KeepAliveLimiter{counts: make(map[string]*keepAliveConnState)}
+}
+
+// LimitRequestsPerConnection increments the request count tracked
+// under key (typically c.Req.RemoteAddr, which stays fixed for the
+// lifetime of one TCP connection and changes on the next one) and
+// calls DisableKeepAlive once maxRequests is reached, so a long-lived
+// client connection gets recycled instead of serving an unbounded
+// number of requests over one socket. It reports whether it did so.
+// maxRequests <= 0 disables the check.
+//
+// A server-wide cap on idle connections is a different, listener-level
+// concern (http.Server.IdleTimeout and the accept-side connection
+// pool) that this request-scoped package has no access to.
+func (l *KeepAliveLimiter) LimitRequestsPerConnection(c Context, key string, maxRequests int) bool {
+	if !l.limitRequests(key, maxRequests, c.Now()) {
+		return false
+	}
+	c.DisableKeepAlive()
+	return true
+}
+
+// limitRequests is the testable core of LimitRequestsPerConnection,
+// taking now explicitly the way RateLimiter.Allow and
+// Banner.RecordFailure do.
+func (l *KeepAliveLimiter) limitRequests(key string, maxRequests int, now time.Time) bool {
+	if maxRequests <= 0 {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.counts[key]
+	if !ok {
+		state = &keepAliveConnState{}
+		l.counts[key] = state
+	}
+	state.count++
+	state.lastSeen = now
+	reached := state.count >= maxRequests
+	if reached {
+		delete(l.counts, key)
+	}
+
+	if l.sweep.due(now, keepAliveSweepInterval) {
+		for k, s := range l.counts {
+			if now.Sub(s.lastSeen) > keepAliveIdleTTL {
+				delete(l.counts, k)
+			}
+		}
+	}
+
+	return reached
+}
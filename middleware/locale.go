@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LocaleOptions configures NegotiateLocale.
+type LocaleOptions struct {
+	// Supported lists the locales (e.g. "en", "fr", "pt-BR") the site
+	// offers, in preference order; Supported[0] is the fallback when
+	// nothing else matches.
+	Supported []string
+
+	// QueryParam, if set, names a query parameter (e.g. "lang") that
+	// overrides negotiation when it's present and names a supported
+	// locale.
+	QueryParam string
+
+	// CookieName, if set, names a cookie (e.g. "lang") that overrides
+	// negotiation, checked after QueryParam and before
+	// Accept-Language, so a visitor's earlier explicit choice sticks
+	// across requests.
+	CookieName string
+}
+
+// NegotiateLocale picks the best locale from opts.Supported for the
+// current request: opts.QueryParam if present and supported, else
+// opts.CookieName if present and supported, else the highest-weighted
+// tag in the Accept-Language header that matches (exactly, or by
+// primary language subtag) a supported locale, else
+// opts.Supported[0]. The chosen locale isn't otherwise stored; a
+// directive assigns it to c.Vars (e.g. c.Vars["locale"] = negotiated)
+// to expose it to templates and proxies as a placeholder.
+func (c Context) NegotiateLocale(opts LocaleOptions) string {
+	if opts.QueryParam != "" {
+		if v := c.Req.URL.Query().Get(opts.QueryParam); v != "" && supportsLocale(opts.Supported, v) {
+			return v
+		}
+	}
+	if opts.CookieName != "" {
+		if v := c.Cookie(opts.CookieName); v != "" && supportsLocale(opts.Supported, v) {
+			return v
+		}
+	}
+	if best := bestAcceptLanguage(c.Header("Accept-Language"), opts.Supported); best != "" {
+		return best
+	}
+	if len(opts.Supported) > 0 {
+		return opts.Supported[0]
+	}
+	return ""
+}
+
+// LocalizedPath prepends "/"+locale to path (e.g.
+// LocalizedPath("fr", "/about") is "/fr/about"), for rewriting a
+// request into a locale-specific subdirectory tree once
+// NegotiateLocale has picked one.
+func LocalizedPath(locale, requestPath string) string {
+	return "/" + strings.Trim(locale, "/") + requestPath
+}
+
+// supportsLocale reports whether locale (case-insensitively) is one of
+// supported.
+func supportsLocale(supported []string, locale string) bool {
+	for _, s := range supported {
+		if strings.EqualFold(s, locale) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptLanguageTag is one weighted entry parsed from an
+// Accept-Language header.
+type acceptLanguageTag struct {
+	tag string
+	q   float64
+}
+
+// parseAcceptLanguage parses header (e.g. "fr-CH, fr;q=0.9, en;q=0.8")
+// into its tags, sorted from highest to lowest q-value (stable, so
+// equal-weight tags keep their original order, per RFC 7231's
+// left-to-right preference when weights tie).
+func parseAcceptLanguage(header string) []acceptLanguageTag {
+	var tags []acceptLanguageTag
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		tag, q := part, 1.0
+		if idx := strings.Index(part, ";q="); idx != -1 {
+			tag = strings.TrimSpace(part[:idx])
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(part[idx+len(";q="):]), 64); err == nil {
+				q = parsed
+			}
+		}
+		tags = append(tags, acceptLanguageTag{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	return tags
+}
+
+// bestAcceptLanguage returns the entry in header, in preference order,
+// that matches a locale in supported: first by exact tag, then by
+// primary language subtag (e.g. "fr-CA" matching a supported "fr"),
+// skipping the "*" wildcard tag. It returns "" if nothing matches.
+func bestAcceptLanguage(header string, supported []string) string {
+	for _, entry := range parseAcceptLanguage(header) {
+		if entry.tag == "*" {
+			continue
+		}
+		if supportsLocale(supported, entry.tag) {
+			for _, s := range supported {
+				if strings.EqualFold(s, entry.tag) {
+					return s
+				}
+			}
+		}
+
+		primary := strings.SplitN(entry.tag, "-", 2)[0]
+		for _, s := range supported {
+			if strings.EqualFold(strings.SplitN(s, "-", 2)[0], primary) {
+				return s
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,570 @@
+package middleware
+
+import (
+	"encoding/json"
+	"html"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// markdownInline handles the subset of inline Markdown spans that show up
+// in template content: bold, italic, inline code, and links. Order
+// matters, since bold must be tried before italic to keep "**x**" from
+// being read as two adjacent italic spans.
+var markdownInline = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\*\*(.+?)\*\*`), "<strong>$1</strong>"},
+	{regexp.MustCompile(`__(.+?)__`), "<strong>$1</strong>"},
+	{regexp.MustCompile(`\*(.+?)\*`), "<em>$1</em>"},
+	{regexp.MustCompile(`_(.+?)_`), "<em>$1</em>"},
+	{regexp.MustCompile("`(.+?)`"), "<code>$1</code>"},
+	{regexp.MustCompile(`\[(.+?)\]\((.+?)\)`), `<a href="$2">$1</a>`},
+}
+
+var markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+var markdownListItem = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+var markdownFence = regexp.MustCompile("^```\\s*([A-Za-z0-9_+-]*)\\s*$")
+var markdownTaskListItem = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+var markdownStrikethrough = regexp.MustCompile(`~~(.+?)~~`)
+var markdownTableRow = regexp.MustCompile(`^\|?(.+?)\|?$`)
+var markdownTableSeparator = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+var markdownFootnoteRef = regexp.MustCompile(`\[\^([^\]]+)\]`)
+var markdownFootnoteDef = regexp.MustCompile(`^\[\^([^\]]+)\]:\s*(.*)$`)
+
+// MarkdownOptions toggles the handful of block-rendering behaviors
+// templates ask for beyond the plain-Markdown default, mirroring the
+// extension toggles a blackfriday-backed renderer would expose.
+type MarkdownOptions struct {
+	// HardLineBreaks turns a single newline within a paragraph into
+	// <br>, instead of Markdown's default of joining wrapped lines
+	// with a space.
+	HardLineBreaks bool
+
+	// HeadingAnchors adds an id="..." slug to each heading, derived
+	// from its text, so sections can be linked to directly and a
+	// generated TableOfContents can point at them.
+	HeadingAnchors bool
+
+	// Tables enables GFM-style pipe tables: a header row, a
+	// |---|---| separator row, and any number of body rows.
+	Tables bool
+
+	// Strikethrough turns ~~text~~ into <del>text</del>.
+	Strikethrough bool
+
+	// TaskLists renders "- [ ] foo" and "- [x] foo" list items as a
+	// disabled checkbox followed by the item text, instead of a plain
+	// list item.
+	TaskLists bool
+
+	// Footnotes turns a [^id] reference into a superscript link and
+	// a "[^id]: text" definition line into an entry in a footnotes
+	// list appended after the document body.
+	Footnotes bool
+
+	// Highlight enables SyntaxHighlight on fenced code blocks, using
+	// HighlightStyle as the style name.
+	Highlight bool
+
+	// HighlightStyle is passed as SyntaxHighlight's styleName when
+	// Highlight is set.
+	HighlightStyle string
+
+	// footnoteDefs accumulates the footnote definitions
+	// extractFootnoteDefs finds while rendering, so the
+	// end-of-document footnotes list can be produced from the same
+	// pass. It's not meant to be set by callers.
+	footnoteDefs []footnoteDef
+}
+
+// renderMarkdown converts source, a minimal Markdown document, to HTML,
+// using the default MarkdownOptions.
+func renderMarkdown(source string) string {
+	return renderMarkdownOpts(source, MarkdownOptions{})
+}
+
+// renderMarkdownOpts converts source to HTML like renderMarkdown, with
+// opts controlling optional block behaviors. It supports the small set
+// of block and inline elements that templates need for prose content:
+// ATX headings, unordered lists, fenced code blocks, paragraphs, and
+// inline bold/italic/code/link spans, plus the extensions opts enables
+// (tables, task lists, strikethrough, footnotes, code highlighting).
+// Anything else is passed through as a paragraph.
+func renderMarkdownOpts(source string, opts MarkdownOptions) string {
+	var out strings.Builder
+
+	lines := strings.Split(source, "\n")
+	if opts.Footnotes {
+		lines = extractFootnoteDefs(lines, &opts)
+	}
+
+	var paragraph []string
+	inList := false
+	inCode := false
+	var codeLang string
+	var codeLines []string
+
+	paragraphSeparator := " "
+	if opts.HardLineBreaks {
+		paragraphSeparator = "<br>\n"
+	}
+
+	flushParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		formatted := make([]string, len(paragraph))
+		for i, line := range paragraph {
+			formatted[i] = markdownInlineHTML(line, opts)
+		}
+		out.WriteString("<p>")
+		out.WriteString(strings.Join(formatted, paragraphSeparator))
+		out.WriteString("</p>\n")
+		paragraph = nil
+	}
+
+	closeList := func() {
+		if inList {
+			out.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+
+	flushCode := func() {
+		out.WriteString("<pre><code")
+		if codeLang != "" {
+			out.WriteString(` class="language-` + html.EscapeString(codeLang) + `"`)
+		}
+		out.WriteString(">")
+		joined := strings.Join(codeLines, "\n")
+		if opts.Highlight {
+			out.WriteString(SyntaxHighlight(joined, codeLang, opts.HighlightStyle))
+		} else {
+			out.WriteString(html.EscapeString(joined))
+		}
+		out.WriteString("</code></pre>\n")
+		inCode, codeLang, codeLines = false, "", nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if inCode {
+			if markdownFence.MatchString(strings.TrimSpace(line)) {
+				flushCode()
+				continue
+			}
+			codeLines = append(codeLines, line)
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			flushParagraph()
+			closeList()
+			continue
+		}
+
+		if m := markdownFence.FindStringSubmatch(trimmed); m != nil {
+			flushParagraph()
+			closeList()
+			inCode = true
+			codeLang = m[1]
+			continue
+		}
+
+		if opts.Tables && i+1 < len(lines) && markdownTableSeparator.MatchString(strings.TrimSpace(lines[i+1])) && strings.Contains(trimmed, "|") {
+			flushParagraph()
+			closeList()
+			consumed := renderMarkdownTable(lines, i, &out, opts)
+			i += consumed - 1
+			continue
+		}
+
+		if m := markdownHeading.FindStringSubmatch(trimmed); m != nil {
+			flushParagraph()
+			closeList()
+			level := len(m[1])
+			out.WriteString("<h")
+			out.WriteByte("0123456"[level])
+			if opts.HeadingAnchors {
+				out.WriteString(` id="` + html.EscapeString(headingSlug(m[2])) + `"`)
+			}
+			out.WriteString(">")
+			out.WriteString(markdownInlineHTML(m[2], opts))
+			out.WriteString("</h")
+			out.WriteByte("0123456"[level])
+			out.WriteString(">\n")
+			continue
+		}
+
+		if m := markdownListItem.FindStringSubmatch(trimmed); m != nil {
+			flushParagraph()
+			if !inList {
+				out.WriteString("<ul>\n")
+				inList = true
+			}
+			out.WriteString("<li>")
+			out.WriteString(markdownListItemHTML(m[1], opts))
+			out.WriteString("</li>\n")
+			continue
+		}
+
+		closeList()
+		paragraph = append(paragraph, trimmed)
+	}
+	flushParagraph()
+	closeList()
+	if inCode {
+		flushCode()
+	}
+
+	if opts.Footnotes && len(opts.footnoteDefs) > 0 {
+		out.WriteString(renderFootnotes(opts.footnoteDefs))
+	}
+
+	return out.String()
+}
+
+// markdownListItemHTML renders one list item's text: a task-list
+// checkbox followed by the remaining text if opts.TaskLists is set and
+// text starts with "[ ]" or "[x]"/"[X]", otherwise the item's text as
+// with any other inline content.
+func markdownListItemHTML(text string, opts MarkdownOptions) string {
+	if opts.TaskLists {
+		if m := markdownTaskListItem.FindStringSubmatch(text); m != nil {
+			checked := ""
+			if strings.EqualFold(m[1], "x") {
+				checked = " checked"
+			}
+			return `<input type="checkbox" disabled` + checked + `> ` + markdownInlineHTML(m[2], opts)
+		}
+	}
+	return markdownInlineHTML(text, opts)
+}
+
+// renderMarkdownTable renders the GFM pipe table starting at lines[start]
+// (a header row immediately followed by a separator row) to out,
+// consuming header, separator, and any contiguous following body rows,
+// and returns the number of lines consumed.
+func renderMarkdownTable(lines []string, start int, out *strings.Builder, opts MarkdownOptions) int {
+	header := splitMarkdownTableRow(lines[start])
+	end := start + 2
+	for end < len(lines) && strings.Contains(lines[end], "|") && strings.TrimSpace(lines[end]) != "" {
+		end++
+	}
+
+	out.WriteString("<table>\n<thead>\n<tr>\n")
+	for _, cell := range header {
+		out.WriteString("<th>" + markdownInlineHTML(cell, opts) + "</th>\n")
+	}
+	out.WriteString("</tr>\n</thead>\n<tbody>\n")
+
+	for _, row := range lines[start+2 : end] {
+		out.WriteString("<tr>\n")
+		for _, cell := range splitMarkdownTableRow(row) {
+			out.WriteString("<td>" + markdownInlineHTML(cell, opts) + "</td>\n")
+		}
+		out.WriteString("</tr>\n")
+	}
+	out.WriteString("</tbody>\n</table>\n")
+
+	return end - start
+}
+
+// splitMarkdownTableRow splits a pipe table row into its trimmed cells,
+// discarding a leading and/or trailing empty cell caused by the row
+// starting and/or ending with "|".
+func splitMarkdownTableRow(row string) []string {
+	m := markdownTableRow.FindStringSubmatch(strings.TrimSpace(row))
+	if m == nil {
+		return nil
+	}
+	fields := strings.Split(m[1], "|")
+	cells := make([]string, len(fields))
+	for i, field := range fields {
+		cells[i] = strings.TrimSpace(field)
+	}
+	return cells
+}
+
+// footnoteDef is one "[^id]: text" definition extracted by
+// extractFootnoteDefs.
+type footnoteDef struct {
+	id   string
+	text string
+}
+
+// extractFootnoteDefs removes any "[^id]: text" definition lines from
+// lines, recording them in opts.footnoteDefs in document order, and
+// returns the remaining lines.
+func extractFootnoteDefs(lines []string, opts *MarkdownOptions) []string {
+	remaining := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if m := markdownFootnoteDef.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			opts.footnoteDefs = append(opts.footnoteDefs, footnoteDef{id: m[1], text: m[2]})
+			continue
+		}
+		remaining = append(remaining, line)
+	}
+	return remaining
+}
+
+// renderFootnotes renders defs as an ordered list of footnote
+// definitions, each with an id footnote references link to.
+func renderFootnotes(defs []footnoteDef) string {
+	var out strings.Builder
+	out.WriteString(`<div class="footnotes">` + "\n<ol>\n")
+	for _, def := range defs {
+		out.WriteString(`<li id="fn-` + html.EscapeString(def.id) + `">` + html.EscapeString(def.text) + "</li>\n")
+	}
+	out.WriteString("</ol>\n</div>\n")
+	return out.String()
+}
+
+// headingSlugChars matches every run of characters that aren't a
+// lowercase letter or digit (hyphens included), for headingSlug.
+var headingSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// headingSlug derives a URL-fragment-safe anchor from heading text,
+// e.g. "Getting Started!" becomes "getting-started".
+func headingSlug(text string) string {
+	slug := headingSlugChars.ReplaceAllString(strings.ToLower(text), "-")
+	return strings.Trim(slug, "-")
+}
+
+// HeadingEntry is one entry in a TableOfContents.
+type HeadingEntry struct {
+	Level int
+	Text  string
+	Slug  string
+}
+
+// TableOfContents parses filename's ATX headings (# through ######)
+// and returns them in document order with the same slugs
+// MarkdownOptions{HeadingAnchors: true} assigns as heading ids, for a
+// template to render as a linked outline alongside the rendered page.
+func (c Context) TableOfContents(filename string) ([]HeadingEntry, error) {
+	if err := c.checkRootBounds(filename); err != nil {
+		return nil, err
+	}
+
+	source, err := c.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	_, body := parseFrontMatter(source)
+
+	var entries []HeadingEntry
+	for _, line := range strings.Split(body, "\n") {
+		m := markdownHeading.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		entries = append(entries, HeadingEntry{
+			Level: len(m[1]),
+			Text:  m[2],
+			Slug:  headingSlug(m[2]),
+		})
+	}
+	return entries, nil
+}
+
+// markdownInlineHTML escapes text and then applies inline Markdown
+// substitutions, so that user content can't inject arbitrary HTML
+// through spans that aren't part of the supported syntax. opts.Strikethrough
+// and opts.Footnotes additionally enable ~~text~~ and [^id] handling.
+func markdownInlineHTML(text string, opts MarkdownOptions) string {
+	escaped := html.EscapeString(text)
+	for _, inline := range markdownInline {
+		escaped = inline.pattern.ReplaceAllString(escaped, inline.replacement)
+	}
+	if opts.Strikethrough {
+		escaped = markdownStrikethrough.ReplaceAllString(escaped, "<del>$1</del>")
+	}
+	if opts.Footnotes {
+		escaped = markdownFootnoteRef.ReplaceAllStringFunc(escaped, func(ref string) string {
+			id := markdownFootnoteRef.FindStringSubmatch(ref)[1]
+			return `<sup id="fnref-` + html.EscapeString(id) + `"><a href="#fn-` + html.EscapeString(id) + `">[` + html.EscapeString(id) + `]</a></sup>`
+		})
+	}
+	return escaped
+}
+
+var yamlFrontMatterLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.*)$`)
+var tomlFrontMatterLine = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*=\s*(.*)$`)
+
+// parseFrontMatter splits a leading YAML (---), TOML (+++), or JSON
+// ({...}) metadata block off of source, returning the parsed keys
+// alongside the remaining document body. If source has no recognized
+// front matter block, it returns a nil map and source unchanged.
+func parseFrontMatter(source string) (map[string]interface{}, string) {
+	switch {
+	case strings.HasPrefix(source, "---\n"):
+		return splitDelimitedFrontMatter(source, "---\n", "\n---\n", parseLineFrontMatter(yamlFrontMatterLine))
+	case strings.HasPrefix(source, "+++\n"):
+		return splitDelimitedFrontMatter(source, "+++\n", "\n+++\n", parseLineFrontMatter(tomlFrontMatterLine))
+	case strings.HasPrefix(strings.TrimLeft(source, " \t"), "{"):
+		return parseJSONFrontMatter(source)
+	default:
+		return nil, source
+	}
+}
+
+// splitDelimitedFrontMatter extracts the block between the opening and
+// closing delimiters of a YAML- or TOML-style front matter section and
+// parses it with parseLines, returning nil if the closing delimiter is
+// missing.
+func splitDelimitedFrontMatter(source, open, close string, parseLines func(string) map[string]interface{}) (map[string]interface{}, string) {
+	rest := source[len(open):]
+	end := strings.Index(rest, close)
+	if end < 0 {
+		return nil, source
+	}
+	return parseLines(rest[:end]), rest[end+len(close):]
+}
+
+// parseLineFrontMatter returns a parser for "key: value" (YAML) or
+// "key = value" (TOML) front matter blocks, using lineRe to split each
+// line and unquoteFrontMatterValue to interpret the value.
+func parseLineFrontMatter(lineRe *regexp.Regexp) func(string) map[string]interface{} {
+	return func(block string) map[string]interface{} {
+		metadata := make(map[string]interface{})
+		for _, line := range strings.Split(block, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			if m := lineRe.FindStringSubmatch(line); m != nil {
+				metadata[m[1]] = unquoteFrontMatterValue(strings.TrimSpace(m[2]))
+			}
+		}
+		return metadata
+	}
+}
+
+// unquoteFrontMatterValue interprets a scalar front matter value as a
+// bool or number where possible, stripping surrounding quotes,
+// otherwise returning it as a plain string.
+func unquoteFrontMatterValue(value string) interface{} {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n
+	}
+	return value
+}
+
+// parseJSONFrontMatter decodes a leading JSON object from source using
+// its self-delimiting structure to find where the object ends, so the
+// remainder is left as the document body.
+func parseJSONFrontMatter(source string) (map[string]interface{}, string) {
+	decoder := json.NewDecoder(strings.NewReader(source))
+	var metadata map[string]interface{}
+	if err := decoder.Decode(&metadata); err != nil {
+		return nil, source
+	}
+	rest := source[decoder.InputOffset():]
+	return metadata, strings.TrimPrefix(rest, "\n")
+}
+
+// ArchiveEntry describes one Markdown page in a directory listing
+// built by Archive, for a blog index or archive page.
+type ArchiveEntry struct {
+	Name     string
+	Metadata map[string]interface{}
+	Excerpt  string
+}
+
+// Archive lists the .md/.markdown files in dirName, relative to the
+// site root, parsing each one's front matter and building a
+// wordCount-word excerpt from its body, sorted by front matter "date"
+// (as a string; ISO 8601 dates therefore sort correctly), newest
+// first. Files without a "date" key sort last.
+func (c Context) Archive(dirName string, wordCount int) ([]ArchiveEntry, error) {
+	infos, err := c.Files(dirName)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ArchiveEntry
+	for _, info := range infos {
+		if info.IsDir() || !MatchesExtension(info.Name(), []string{".md", ".markdown"}) {
+			continue
+		}
+		if c.isHidden(info.Name()) {
+			continue
+		}
+
+		body, err := c.readFile(path.Join(dirName, info.Name()))
+		if err != nil {
+			continue
+		}
+
+		metadata, rest := parseFrontMatter(body)
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+
+		entries = append(entries, ArchiveEntry{
+			Name:     info.Name(),
+			Metadata: metadata,
+			Excerpt:  excerptWords(rest, wordCount),
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return archiveDate(entries[i]) > archiveDate(entries[j])
+	})
+
+	return entries, nil
+}
+
+// ArchivePage returns up to limit entries of Archive(dirName,
+// wordCount), starting at offset, along with the total number of
+// matching files, for a paginated blog index.
+func (c Context) ArchivePage(dirName string, offset, limit, wordCount int) (entries []ArchiveEntry, total int, err error) {
+	all, err := c.Archive(dirName, wordCount)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total = len(all)
+	if offset >= total {
+		return []ArchiveEntry{}, total, nil
+	}
+
+	end := offset + limit
+	if end > total || limit <= 0 {
+		end = total
+	}
+
+	return all[offset:end], total, nil
+}
+
+// archiveDate returns entry's front-matter "date" string, or "" if it
+// has none, so entries without a date sort after those with one.
+func archiveDate(entry ArchiveEntry) string {
+	date, _ := entry.Metadata["date"].(string)
+	return date
+}
+
+// excerptWords returns the first n whitespace-separated words of
+// source, appending "..." if source was truncated.
+func excerptWords(source string, n int) string {
+	fields := strings.Fields(source)
+	if len(fields) <= n {
+		return strings.Join(fields, " ")
+	}
+	return strings.Join(fields[:n], " ") + "..."
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+)
+
+// Matcher reports whether a request, as seen through c, satisfies some
+// condition. It's the shared building block behind directives like
+// rewrite, redir, and header, which otherwise each grow their own
+// ad-hoc "if" logic; plugin authors can compose the constructors below
+// with And, Or, and Not instead of reimplementing matching.
+type Matcher func(c Context) bool
+
+// Not returns a Matcher that matches when m doesn't.
+func Not(m Matcher) Matcher {
+	return func(c Context) bool { return !m(c) }
+}
+
+// And returns a Matcher that matches only when every one of matchers
+// does. An empty matchers list always matches.
+func And(matchers ...Matcher) Matcher {
+	return func(c Context) bool {
+		for _, m := range matchers {
+			if !m(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a Matcher that matches when any one of matchers does. An
+// empty matchers list never matches.
+func Or(matchers ...Matcher) Matcher {
+	return func(c Context) bool {
+		for _, m := range matchers {
+			if m(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MethodMatcher returns a Matcher that matches when the request method
+// is one of methods; see Context.MethodIs.
+func MethodMatcher(methods ...string) Matcher {
+	return func(c Context) bool { return c.MethodIs(methods...) }
+}
+
+// PathMatcher returns a Matcher that matches when the request path
+// matches pattern; see Context.PathMatches.
+func PathMatcher(pattern string) Matcher {
+	return func(c Context) bool { return c.PathMatches(pattern) }
+}
+
+// HeaderMatcher returns a Matcher that matches when the request header
+// name equals value exactly.
+func HeaderMatcher(name, value string) Matcher {
+	return func(c Context) bool { return c.Header(name) == value }
+}
+
+// QueryMatcher returns a Matcher that matches when the query string
+// parameter name equals value exactly.
+func QueryMatcher(name, value string) Matcher {
+	return func(c Context) bool { return c.Query(name) == value }
+}
+
+// SchemeMatcher returns a Matcher that matches when the request scheme
+// (as determined by Context.Scheme) is one of schemes, compared
+// case-insensitively.
+func SchemeMatcher(schemes ...string) Matcher {
+	return func(c Context) bool {
+		for _, scheme := range schemes {
+			if strings.EqualFold(c.Scheme(), scheme) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CookieMatcher returns a Matcher that matches when the request has a
+// cookie named name, and, if value is non-empty, that cookie's value
+// equals value exactly. Passing an empty value therefore matches on
+// the cookie's mere presence, e.g. CookieMatcher("session", "") for
+// "logged in at all".
+func CookieMatcher(name, value string) Matcher {
+	return func(c Context) bool {
+		cookie, err := c.Req.Cookie(name)
+		if err != nil {
+			return false
+		}
+		return value == "" || cookie.Value == value
+	}
+}
+
+// RemoteIPMatcher returns a Matcher that matches when the request's
+// client IP (see Context.ClientIP) falls within one of cidrs. A CIDR
+// that fails to parse never matches, rather than making the whole
+// Matcher error out.
+func RemoteIPMatcher(cidrs ...string) Matcher {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return func(c Context) bool {
+		ip := net.ParseIP(c.ClientIP())
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+}
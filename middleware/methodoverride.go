@@ -0,0 +1,37 @@
+package middleware
+
+import "net/http"
+
+// MethodOverrideHeader is the header OverrideMethod checks for a
+// client-requested method override.
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// MethodOverrideFormField is the form field OverrideMethod falls back
+// to checking when MethodOverrideHeader isn't set.
+const MethodOverrideFormField = "_method"
+
+// OverrideMethod rewrites the request's method to the value of
+// MethodOverrideHeader, or of the MethodOverrideFormField form field if
+// the header isn't set, for a POST request behind a restrictive proxy
+// or client that can't send PUT, PATCH, or DELETE directly to a proxied
+// REST backend. It only ever overrides POST: a caller invokes it for
+// whichever paths should honor the override (e.g.
+// {{if .PathMatches "/api/*"}}{{.OverrideMethod}}{{end}}), and it's a
+// no-op on any other method or when neither the header nor the form
+// field is set. It returns the method the request now has.
+func (c Context) OverrideMethod() string {
+	if c.Req.Method != http.MethodPost {
+		return c.Req.Method
+	}
+
+	override := c.Req.Header.Get(MethodOverrideHeader)
+	if override == "" {
+		override = c.Req.PostFormValue(MethodOverrideFormField)
+	}
+	if override == "" {
+		return c.Req.Method
+	}
+
+	c.Req.Method = override
+	return c.Req.Method
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"path"
+	"strings"
+)
+
+// MimeTypeForExtension returns the Content-Type to serve name with,
+// looked up by its extension (as DelimsForExtension and
+// SetExpiresForExtension are) in types, falling back to defaultType
+// if name's extension isn't in types. If defaultCharset is set and
+// the resolved type doesn't already carry a charset parameter,
+// "; charset=" + defaultCharset is appended, so a directive can force
+// e.g. UTF-8 on text types without a per-extension override needing
+// to spell it out itself.
+func (c Context) MimeTypeForExtension(name string, types map[string]string, defaultType, defaultCharset string) string {
+	mimeType, ok := types[path.Ext(name)]
+	if !ok {
+		mimeType = defaultType
+	}
+	if mimeType != "" && defaultCharset != "" && !strings.Contains(mimeType, "charset=") {
+		mimeType += "; charset=" + defaultCharset
+	}
+	return mimeType
+}
+
+// ParseMimeTypes parses an Apache/nginx-style mime.types file (a MIME
+// type per line followed by its whitespace-separated extensions, e.g.
+// "text/html html htm"; blank lines and lines starting with "#" are
+// ignored) into an extension-to-type map suitable for
+// MimeTypeForExtension, keyed the same way path.Ext returns them
+// (with a leading dot).
+func ParseMimeTypes(data string) map[string]string {
+	types := make(map[string]string)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, ext := range fields[1:] {
+			types["."+ext] = fields[0]
+		}
+	}
+	return types
+}
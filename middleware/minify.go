@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// MinifyOptions selects which content types Minify skips, for a
+// `minify` directive to disable minification for one type without
+// turning off the whole feature.
+type MinifyOptions struct {
+	DisableHTML bool
+	DisableCSS  bool
+	DisableJS   bool
+	DisableJSON bool
+	DisableSVG  bool
+}
+
+// minifyPreservedTags lists HTML tags whose content Minify leaves
+// untouched, since collapsing whitespace inside them would change
+// what's rendered or executed.
+var minifyPreservedTags = []string{"pre", "textarea", "script", "style"}
+
+// Minify shrinks body according to contentType (matched by prefix,
+// e.g. "text/html; charset=utf-8" still matches "text/html"),
+// skipping any type disabled in opts, for a `minify` directive to
+// shave bytes off template/markdown output automatically. It's a
+// byte-saving pass, not a full parser: CSS/JS minification only
+// strips block comments and collapses whitespace runs, and won't
+// rename identifiers or fold rules the way a dedicated minifier
+// library would, since this tree has no module management to add one.
+// Applying this to a live, streamed response ahead of gzip — the
+// order the request described — needs the reverse-proxy/streaming
+// layer this tree doesn't have; a directive buffers the body (as
+// RewriteBody already does), calls this, then SetBodyContentLength
+// before writing the result.
+func Minify(contentType, body string, opts MinifyOptions) string {
+	switch {
+	case !opts.DisableHTML && strings.HasPrefix(contentType, "text/html"):
+		return collapseWhitespacePreservingTags(stripHTMLComments(body), minifyPreservedTags)
+	case !opts.DisableCSS && strings.HasPrefix(contentType, "text/css"):
+		return collapseWhitespaceRuns(stripBlockComments(body))
+	case !opts.DisableJS && (strings.HasPrefix(contentType, "application/javascript") || strings.HasPrefix(contentType, "text/javascript")):
+		return collapseWhitespaceRuns(stripBlockComments(body))
+	case !opts.DisableJSON && strings.HasPrefix(contentType, "application/json"):
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(body)); err != nil {
+			return body
+		}
+		return buf.String()
+	case !opts.DisableSVG && strings.HasPrefix(contentType, "image/svg+xml"):
+		return collapseWhitespaceRuns(stripHTMLComments(body))
+	default:
+		return body
+	}
+}
+
+// stripHTMLComments removes "<!-- ... -->" comments from s, except a
+// conditional comment ("<!--[if ...") or its matching
+// "<!--<![endif]-->", which IE relies on for content it should still
+// render.
+func stripHTMLComments(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "<!--")
+		if start == -1 {
+			b.WriteString(s)
+			break
+		}
+		relEnd := strings.Index(s[start:], "-->")
+		if relEnd == -1 {
+			b.WriteString(s)
+			break
+		}
+		end := start + relEnd + len("-->")
+		comment := s[start:end]
+
+		b.WriteString(s[:start])
+		if strings.HasPrefix(comment, "<!--[if") || strings.HasPrefix(comment, "<!--<![endif]") {
+			b.WriteString(comment)
+		}
+		s = s[end:]
+	}
+	return b.String()
+}
+
+// stripBlockComments removes "/* ... */" comments from s.
+func stripBlockComments(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "/*")
+		if start == -1 {
+			b.WriteString(s)
+			break
+		}
+		relEnd := strings.Index(s[start:], "*/")
+		if relEnd == -1 {
+			b.WriteString(s[:start])
+			break
+		}
+		b.WriteString(s[:start])
+		s = s[start+relEnd+len("*/"):]
+	}
+	return b.String()
+}
+
+// collapseWhitespaceRuns replaces every run of whitespace in s with a
+// single space and trims the result.
+func collapseWhitespaceRuns(s string) string {
+	var b strings.Builder
+	lastSpace := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			if !lastSpace {
+				b.WriteByte(' ')
+			}
+			lastSpace = true
+			continue
+		}
+		lastSpace = false
+		b.WriteRune(r)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// collapseWhitespacePreservingTags behaves like collapseWhitespaceRuns,
+// except it leaves whitespace untouched between any opening tag named
+// in preserved and its matching closing tag (e.g. inside <pre> or
+// <script>).
+func collapseWhitespacePreservingTags(s string, preserved []string) string {
+	var b strings.Builder
+	inTag := ""
+	i := 0
+	for i < len(s) {
+		if s[i] == '<' {
+			end := strings.IndexByte(s[i:], '>')
+			if end == -1 {
+				b.WriteString(s[i:])
+				break
+			}
+			tag := s[i : i+end+1]
+			b.WriteString(tag)
+			i += end + 1
+
+			lower := strings.ToLower(tag)
+			for _, name := range preserved {
+				if inTag == "" && strings.HasPrefix(lower, "<"+name) {
+					inTag = name
+				} else if inTag == name && strings.HasPrefix(lower, "</"+name) {
+					inTag = ""
+				}
+			}
+			continue
+		}
+
+		if inTag != "" {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		if s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r' {
+			b.WriteByte(' ')
+			for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+				i++
+			}
+			continue
+		}
+
+		b.WriteByte(s[i])
+		i++
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// Mirror is one candidate download mirror in a MirrorTable. BaseURL is
+// prefixed to the current request path to build the redirect target;
+// Region, if set, restricts the mirror to clients GeoIP places in that
+// region; Weight controls how often it's chosen among mirrors tied on
+// region (higher is more likely, 0 counts as 1).
+type Mirror struct {
+	BaseURL string
+	Region  string
+	Weight  int
+}
+
+// ErrNoMirrorAvailable is returned by SelectMirror when table has no
+// mirror eligible for the current request.
+var ErrNoMirrorAvailable = errors.New("middleware: no mirror available for this client")
+
+// MirrorTable is a set of candidate download mirrors for
+// SelectMirror. It doesn't health-check its mirrors: that needs a
+// background prober outliving a single request's Context, so an
+// operator is expected to remove an unhealthy Mirror from Mirrors
+// externally (e.g. from a periodic job) rather than have SelectMirror
+// probe it inline on the request path.
+type MirrorTable struct {
+	Mirrors []Mirror
+}
+
+// SelectMirror picks a Mirror from table for the current request:
+// mirrors whose Region matches the client's GeoIP region are
+// preferred, falling back to mirrors with an empty Region if none
+// match, weighted at random by Weight among the eligible set. A
+// directive redirects to the chosen Mirror's BaseURL plus the current
+// request path, e.g. c.Redirect(mirror.BaseURL+c.URI(), 302).
+func (c Context) SelectMirror(table MirrorTable) (Mirror, error) {
+	region, _ := c.GeoIP()
+
+	candidates := mirrorsInRegion(table.Mirrors, region.Region)
+	if len(candidates) == 0 {
+		candidates = mirrorsInRegion(table.Mirrors, "")
+	}
+	if len(candidates) == 0 {
+		return Mirror{}, ErrNoMirrorAvailable
+	}
+
+	return weightedMirrorChoice(candidates), nil
+}
+
+// mirrorsInRegion returns the mirrors in mirrors whose Region equals
+// region.
+func mirrorsInRegion(mirrors []Mirror, region string) []Mirror {
+	var matches []Mirror
+	for _, mirror := range mirrors {
+		if mirror.Region == region {
+			matches = append(matches, mirror)
+		}
+	}
+	return matches
+}
+
+// weightedMirrorChoice picks one of candidates at random, weighted by
+// Weight (a Weight of 0 counts as 1).
+func weightedMirrorChoice(candidates []Mirror) Mirror {
+	total := 0
+	for _, mirror := range candidates {
+		total += mirrorWeight(mirror)
+	}
+
+	pick := rand.Intn(total)
+	for _, mirror := range candidates {
+		pick -= mirrorWeight(mirror)
+		if pick < 0 {
+			return mirror
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// mirrorWeight returns mirror.Weight, or 1 if it's zero.
+func mirrorWeight(mirror Mirror) int {
+	if mirror.Weight == 0 {
+		return 1
+	}
+	return mirror.Weight
+}
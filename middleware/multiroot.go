@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafeHostRoot is returned by RootForHost when the current
+// request's Host would escape template's directory structure.
+var ErrUnsafeHostRoot = errors.New("middleware: host is unsafe to use in a root path")
+
+// RootForPath returns the root directory the current request should
+// be served from, by matching the current request path against the
+// longest matching prefix key in roots, falling back to defaultRoot
+// if none match, e.g. {"/static/": "/srv/assets", "/media/": "/mnt/media"}
+// to serve those two prefixes from separate mounts within one site
+// while everything else uses defaultRoot. A directive resolves this
+// before setting c.Root (or computing a FastCGI script path), so the
+// rest of the file-serving/scripting pipeline doesn't need to know
+// multiple roots are in play.
+func (c Context) RootForPath(roots map[string]string, defaultRoot string) string {
+	best := defaultRoot
+	bestLen := -1
+
+	for prefix, root := range roots {
+		if strings.HasPrefix(c.Req.URL.Path, prefix) && len(prefix) > bestLen {
+			best = root
+			bestLen = len(prefix)
+		}
+	}
+
+	return best
+}
+
+// RootForHost expands template's placeholders (typically just
+// "{host}") against the current request, e.g. "/srv/{host}" for a
+// request to "example.com" resolving to "/srv/example.com", so mass
+// virtual hosting of many domains' docroots needs one site block
+// instead of one per domain. It errors with ErrUnsafeHostRoot if the
+// current Host contains a path separator or is "." or "..", since
+// Host is attacker-controlled and template is otherwise assembled
+// directly into a filesystem path.
+func (c Context) RootForHost(template string) (string, error) {
+	host, err := c.Host()
+	if err != nil {
+		return "", err
+	}
+	if host == "" || host == "." || host == ".." || strings.ContainsAny(host, `/\`) {
+		return "", ErrUnsafeHostRoot
+	}
+	return c.Expand(CompileFormat(template)), nil
+}
+
+// RootForHostIfExists reports whether a directory for the current
+// request's Host exists under baseDir, following the
+// "<baseDir>/<host>/<publicSubdir>" layout, e.g.
+// RootForHostIfExists("/srv/vhosts", "public") looking for
+// "/srv/vhosts/example.com/public". It returns that directory and
+// true if it exists, or "" and false otherwise, so shared-hosting
+// deployments can map any Host to its docroot without per-site
+// config, 404ing hosts that don't have one. Certificate issuance for
+// a Host discovered this way (on-demand TLS) needs the ACME/cert
+// issuance layer this tree doesn't have.
+func (c Context) RootForHostIfExists(baseDir, publicSubdir string) (string, bool) {
+	host, err := c.Host()
+	if err != nil || host == "" || host == "." || host == ".." || strings.ContainsAny(host, `/\`) {
+		return "", false
+	}
+
+	dir := filepath.Join(baseDir, host, publicSubdir)
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return dir, true
+}
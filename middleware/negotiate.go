@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"path"
+	"strings"
+)
+
+// imageNegotiationFormats are tried in preference order against the
+// request's Accept header by NegotiateImage.
+var imageNegotiationFormats = []string{"avif", "webp"}
+
+// NegotiateImage returns the path to serve for an image request at
+// name: a same-directory sibling in a modern format (name.avif, then
+// name.webp, e.g. "photo.jpg" -> "photo.webp") if the client's Accept
+// header indicates support for that format and the sibling file
+// exists under the site root, falling back to name itself otherwise.
+// It doesn't generate the sibling files: this package has no WebP or
+// AVIF encoder, so a build step or the git/upload middlewares are
+// expected to have produced them ahead of time. It also sets the
+// response's Vary: Accept header, since the choice of body depends on
+// that request header.
+func (c Context) NegotiateImage(name string) (string, error) {
+	c.AddHeader("Vary", "Accept")
+
+	accept := c.Header("Accept")
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for _, format := range imageNegotiationFormats {
+		if !strings.Contains(accept, "image/"+format) {
+			continue
+		}
+		candidate := base + "." + format
+		if c.FileExists(candidate) {
+			return candidate, nil
+		}
+	}
+
+	return name, nil
+}
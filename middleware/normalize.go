@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// NormalizePath decodes rawPath's percent-encodings, merges duplicate
+// slashes, and resolves "." and ".." segments, returning a canonical
+// form that a matcher, rewrite, or ipfilter rule can be evaluated
+// against without being bypassed by an encoded or malformed path (e.g.
+// "/admin" hidden behind "/%2e/foo/../admin" or "//admin"). It errors
+// on a rawPath that doesn't decode as valid UTF-8 or contains a NUL
+// byte, rather than guessing at an interpretation. It's equivalent to
+// NormalizePathWithOptions with the zero value NormalizePathOptions,
+// which decodes an encoded slash (%2F) rather than denying it.
+func NormalizePath(rawPath string) (string, error) {
+	return NormalizePathWithOptions(rawPath, NormalizePathOptions{})
+}
+
+// NormalizePathOptions configures NormalizePathWithOptions.
+type NormalizePathOptions struct {
+	// DenyEncodedSlash rejects rawPath if it contains an encoded
+	// slash (%2F or %2f) before it's decoded, since decoding one into
+	// a literal "/" can smuggle an extra path segment past a matcher
+	// or upstream that only inspected the pre-decode raw path.
+	DenyEncodedSlash bool
+}
+
+// NormalizePathWithOptions behaves like NormalizePath, additionally
+// denying encoded-slash tricks per opts.
+func NormalizePathWithOptions(rawPath string, opts NormalizePathOptions) (string, error) {
+	if opts.DenyEncodedSlash && strings.Contains(strings.ToLower(rawPath), "%2f") {
+		return "", fmt.Errorf("middleware: encoded slash denied in path %q", rawPath)
+	}
+
+	decoded, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return "", fmt.Errorf("middleware: invalid percent-encoding in path %q: %w", rawPath, err)
+	}
+	if strings.ContainsRune(decoded, 0) {
+		return "", fmt.Errorf("middleware: NUL byte in path %q", rawPath)
+	}
+	if !utf8.ValidString(decoded) {
+		return "", fmt.Errorf("middleware: invalid UTF-8 in path %q", rawPath)
+	}
+
+	cleaned := path.Clean(decoded)
+	if !strings.HasPrefix(cleaned, "/") {
+		cleaned = "/" + cleaned
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import "strconv"
+
+// PageLink describes one page of a paginated listing, with the URL to
+// reach it, for a template to render "Prev 1 2 3 Next" style
+// navigation without doing arithmetic on the query string itself.
+type PageLink struct {
+	Number  int
+	URL     string
+	Current bool
+}
+
+// Paginate computes the current page number (from the "page" query
+// string parameter, defaulting to 1, and clamped to the valid range)
+// and a PageLink for every page of a listing of total items shown
+// pageSize at a time, each URL pointing at the current request path
+// with its "page" parameter set accordingly. Pair it with PageOffset
+// to compute the offset ListDirPage or ArchivePage need for the
+// current page.
+func (c Context) Paginate(total, pageSize int) (current int, pages []PageLink) {
+	if pageSize <= 0 {
+		pageSize = total
+	}
+
+	pageCount := 1
+	if pageSize > 0 {
+		pageCount = (total + pageSize - 1) / pageSize
+		if pageCount < 1 {
+			pageCount = 1
+		}
+	}
+
+	current, _ = strconv.Atoi(c.Query("page"))
+	if current < 1 {
+		current = 1
+	}
+	if current > pageCount {
+		current = pageCount
+	}
+
+	query := c.Req.URL.Query()
+	pages = make([]PageLink, pageCount)
+	for i := range pages {
+		number := i + 1
+		query.Set("page", strconv.Itoa(number))
+		pages[i] = PageLink{
+			Number:  number,
+			URL:     c.Req.URL.Path + "?" + query.Encode(),
+			Current: number == current,
+		}
+	}
+
+	return current, pages
+}
+
+// PageOffset returns the offset into a listing of total items, shown
+// pageSize at a time, for the given 1-based page number, for use as
+// the offset argument to ListDirPage or ArchivePage.
+func (c Context) PageOffset(page, pageSize int) int {
+	if page < 1 {
+		page = 1
+	}
+	return (page - 1) * pageSize
+}
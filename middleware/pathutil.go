@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// joinUnderRoot resolves name (a slash-separated request/upload path)
+// to an OS path under dir, the way http.Dir clamps read paths: name is
+// cleaned as an absolute slash path first, so "../../etc/passwd"
+// collapses to "/etc/passwd" and can't climb above dir, then converted
+// to the OS's native separator and drive/case conventions via
+// filepath.FromSlash. Include and upload both resolve a request path
+// this way rather than repeating the pattern inline.
+func joinUnderRoot(dir http.Dir, name string) string {
+	return filepath.Join(string(dir), filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// withinRoot reports whether candidate is root itself or a descendant
+// of it, comparing them with filepath.Rel rather than a raw string
+// prefix so the check is correct regardless of trailing separators.
+// Note it compares byte-for-byte: on a case-insensitive filesystem
+// (e.g. Windows, or a case-insensitive HFS+/APFS volume), a candidate
+// differing from root only in case is treated as outside it, which is
+// stricter than the OS itself but never lets a real escape through.
+func withinRoot(root, candidate string) bool {
+	rel, err := filepath.Rel(root, candidate)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
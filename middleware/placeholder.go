@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// placeholders maps a Caddy-style {name} placeholder to the Context
+// method that resolves it, so templates, headers, and logging can
+// eventually share one variable vocabulary instead of each middleware
+// inventing its own.
+var placeholders = map[string]func(c Context) (string, error){
+	"remote":   func(c Context) (string, error) { return c.ClientIP(), nil },
+	"host":     func(c Context) (string, error) { return c.Host() },
+	"uri":      func(c Context) (string, error) { return c.Req.URL.RequestURI(), nil },
+	"path":     func(c Context) (string, error) { return c.Req.URL.Path, nil },
+	"method":   func(c Context) (string, error) { return c.Method(), nil },
+	"scheme":   func(c Context) (string, error) { return c.Scheme(), nil },
+	"query":    func(c Context) (string, error) { return c.Req.URL.RawQuery, nil },
+	"protocol": func(c Context) (string, error) { return c.HTTPVersion(), nil },
+
+	"tls_sni":            func(c Context) (string, error) { return c.TLSServerName(), nil },
+	"tls_version":        func(c Context) (string, error) { return c.TLSVersionName(), nil },
+	"tls_cipher":         func(c Context) (string, error) { return c.TLSCipherSuiteName(), nil },
+	"tls_resumed":        func(c Context) (string, error) { return strconv.FormatBool(c.TLSResumed()), nil },
+	"tls_client_subject": func(c Context) (string, error) { return c.TLSClientCertSubject(), nil },
+}
+
+// Placeholder resolves name (without its surrounding braces, e.g.
+// "remote" for "{remote}") to the current request's value for it,
+// using the same names as the header and logging placeholders. It
+// returns an error if name isn't a recognized placeholder.
+func (c Context) Placeholder(name string) (string, error) {
+	resolve, ok := placeholders[name]
+	if !ok {
+		return "", fmt.Errorf("middleware: unrecognized placeholder %q", name)
+	}
+	return resolve(c)
+}
+
+// Map looks input up in table and returns the matching value, or
+// defaultValue if input isn't a key in table, for turning one
+// placeholder's value into another (e.g. a country code into a
+// backend, or a path prefix into a tenant ID) the way a map directive
+// would, without proxy/rewrite/headers each growing their own lookup
+// logic.
+func (c Context) Map(input string, table map[string]string, defaultValue string) string {
+	if value, ok := table[input]; ok {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"bytes"
+	"strings"
+)
+
+// placeholderToken is one piece of a CompiledFormat: either a literal
+// chunk of text (name is empty) or a reference to a Placeholder name
+// to resolve per request.
+type placeholderToken struct {
+	literal string
+	name    string
+}
+
+// CompiledFormat is a format string like "{remote} - [{method}] {path}"
+// broken into literal and {name} placeholder tokens once, up front, by
+// CompileFormat, so Expand doesn't have to re-scan the format string
+// and rebuild it by concatenation on every request, the way repeatedly
+// calling Placeholder against raw format text would.
+type CompiledFormat struct {
+	tokens []placeholderToken
+}
+
+// CompileFormat parses format into a CompiledFormat for Expand, for a
+// directive (an access log line, a debug response header) that
+// expands the same format on every request and wants to pay the
+// parsing cost once, at setup time, instead of per request. An
+// unterminated "{" is kept as a literal.
+func CompileFormat(format string) CompiledFormat {
+	var tokens []placeholderToken
+	for len(format) > 0 {
+		start := strings.IndexByte(format, '{')
+		if start == -1 {
+			tokens = append(tokens, placeholderToken{literal: format})
+			break
+		}
+		if start > 0 {
+			tokens = append(tokens, placeholderToken{literal: format[:start]})
+		}
+		format = format[start+1:]
+
+		end := strings.IndexByte(format, '}')
+		if end == -1 {
+			tokens = append(tokens, placeholderToken{literal: "{" + format})
+			break
+		}
+		tokens = append(tokens, placeholderToken{name: format[:end]})
+		format = format[end+1:]
+	}
+	return CompiledFormat{tokens: tokens}
+}
+
+// Expand resolves compiled's placeholders against the current request
+// and writes the result into a buffer drawn from bufferPool, the same
+// pool Include uses, rather than building the result through repeated
+// string concatenation. An unrecognized {name} expands to "".
+func (c Context) Expand(compiled CompiledFormat) string {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	for _, token := range compiled.tokens {
+		if token.name == "" {
+			buf.WriteString(token.literal)
+			continue
+		}
+		if value, err := c.Placeholder(token.name); err == nil {
+			buf.WriteString(value)
+		}
+	}
+	return buf.String()
+}
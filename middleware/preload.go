@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// preloadTags are the tags PreloadLinks scans html for, each paired
+// with the "as" value its matches should preload as.
+var preloadTags = []struct {
+	pattern *regexp.Regexp
+	as      string
+}{
+	{regexp.MustCompile(`<link[^>]+rel=["']stylesheet["'][^>]*href=["']([^"']+)["']`), "style"},
+	{regexp.MustCompile(`<script[^>]+src=["']([^"']+)["']`), "script"},
+}
+
+// PreloadLinks scans html for <link rel="stylesheet"> and <script src>
+// tags and returns a Link: preload header value for each, in the order
+// they appear, so the response can advertise critical CSS/JS before the
+// browser finishes parsing the body.
+func PreloadLinks(html string) []string {
+	var links []string
+	for _, tag := range preloadTags {
+		for _, m := range tag.pattern.FindAllStringSubmatch(html, -1) {
+			links = append(links, fmt.Sprintf(`<%s>; rel=preload; as=%s`, m[1], tag.as))
+		}
+	}
+	return links
+}
+
+// SetPreloadHeaders adds a Link: preload response header for each
+// resource PreloadLinks finds in html, for an HTTP/2 server to push or
+// a browser to start fetching ahead of parsing the rest of the page.
+func (c Context) SetPreloadHeaders(html string) {
+	for _, link := range PreloadLinks(html) {
+		c.AddHeader("Link", link)
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+)
+
+//go:generate go run gen_psl.go
+
+// publicSuffix returns the public suffix of host and whether it is an
+// ICANN-managed (rather than privately registered) suffix, applying
+// the Public Suffix List's formal algorithm (see
+// https://publicsuffix.org/list/): the longest matching rule wins,
+// where a wildcard rule (pslWildcards) matches one extra label beyond
+// its own, an exception rule (pslExceptions) removes a label from
+// what would otherwise be a wildcard match, and, if nothing matches,
+// the last label of host is treated as the (unmanaged) public suffix.
+// This is the same algorithm net/http/cookiejar's jarKey applies via
+// its PublicSuffixList interface; see gen_psl.go for how pslRules,
+// pslWildcards, and pslExceptions are (re)generated from the
+// canonical list at https://publicsuffix.org/list/public_suffix_list.dat.
+func publicSuffix(host string) (suffix string, icann bool) {
+	labels := strings.Split(host, ".")
+
+	for i := 0; i < len(labels); i++ {
+		candidate := strings.Join(labels[i:], ".")
+
+		if _, ok := pslExceptions[candidate]; ok {
+			return strings.Join(labels[i+1:], "."), true
+		}
+		if _, ok := pslRules[candidate]; ok {
+			return candidate, true
+		}
+		if _, ok := pslWildcards[strings.Join(labels[i+1:], ".")]; ok {
+			return candidate, true
+		}
+	}
+
+	return labels[len(labels)-1], false
+}
+
+// canonicalHost strips an optional trailing dot and port from host and
+// lowercases the result, mirroring net/http/cookiejar's jarKey
+// preprocessing.
+func canonicalHost(host string) string {
+	host = strings.TrimSuffix(host, ".")
+	host = strings.ToLower(host)
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return host
+}
+
+// RegisteredDomain returns the effective top-level-domain-plus-one
+// (eTLD+1) of the request Host, e.g. "foo.co.uk" for
+// "a.b.foo.co.uk". IP literal hosts (v4 and v6, bracketed or not) are
+// returned unchanged. If host is itself a public suffix, the empty
+// string is returned.
+func (c Context) RegisteredDomain() string {
+	host := canonicalHost(c.Req.Host)
+
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return host
+	}
+
+	suffix, _ := publicSuffix(host)
+	if suffix == host {
+		return ""
+	}
+
+	labels := strings.Split(host, ".")
+	suffixLabels := strings.Split(suffix, ".")
+	if len(labels) <= len(suffixLabels) {
+		return ""
+	}
+
+	registeredLabels := labels[len(labels)-len(suffixLabels)-1:]
+	return strings.Join(registeredLabels, ".")
+}
+
+// Subdomain returns the portion of the request Host preceding the
+// RegisteredDomain, e.g. "a.b" for "a.b.foo.co.uk" when the registered
+// domain is "foo.co.uk". It returns the empty string when there is no
+// subdomain, or when Host is an IP literal or a bare public suffix.
+func (c Context) Subdomain() string {
+	host := canonicalHost(c.Req.Host)
+	registered := c.RegisteredDomain()
+	if registered == "" || registered == host {
+		return ""
+	}
+
+	sub := strings.TrimSuffix(host, "."+registered)
+	if sub == host {
+		return ""
+	}
+	return sub
+}
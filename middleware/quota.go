@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"path"
+	"sync"
+)
+
+// DirectoryQuota tracks cumulative bytes served and requests handled
+// per directory, keyed by an arbitrary string (typically the request
+// path's directory), so a `browse` or file-serving directive can
+// expose usage statistics and enforce a soft quota that switches a
+// directory to 503 once exceeded. The zero value is ready to use.
+type DirectoryQuota struct {
+	mu    sync.Mutex
+	usage map[string]*directoryUsage
+}
+
+// directoryUsage accumulates one directory's counters.
+type directoryUsage struct {
+	bytes    int64
+	requests int64
+}
+
+// Record adds bytesServed to key's running total and increments its
+// request count, then reports whether the resulting byte total meets
+// or exceeds maxBytes (a maxBytes of 0 means unlimited, always
+// reporting false). A directive calls this once per served request
+// and returns 503 when it reports true.
+func (q *DirectoryQuota) Record(key string, bytesServed, maxBytes int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.usage == nil {
+		q.usage = make(map[string]*directoryUsage)
+	}
+	entry, ok := q.usage[key]
+	if !ok {
+		entry = &directoryUsage{}
+		q.usage[key] = entry
+	}
+
+	entry.bytes += bytesServed
+	entry.requests++
+
+	return maxBytes > 0 && entry.bytes >= maxBytes
+}
+
+// Usage returns key's current byte and request totals.
+func (q *DirectoryQuota) Usage(key string) (bytes, requests int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.usage[key]
+	if !ok {
+		return 0, 0
+	}
+	return entry.bytes, entry.requests
+}
+
+// RecordDirectoryUsage records bytesServed against the directory of
+// the current request path in quota, reporting whether maxBytes has
+// been reached, for a directive to enforce a per-directory soft quota.
+func (c Context) RecordDirectoryUsage(quota *DirectoryQuota, bytesServed, maxBytes int64) bool {
+	return quota.Record(path.Dir(c.Req.URL.Path), bytesServed, maxBytes)
+}
+
+// DirectoryUsage returns the byte and request totals recorded so far
+// for the current request path's directory, for the browse JSON
+// output to expose alongside its file listing.
+func (c Context) DirectoryUsage(quota *DirectoryQuota) (bytes, requests int64) {
+	return quota.Usage(path.Dir(c.Req.URL.Path))
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiterSweepInterval bounds how often Allow walks the whole
+// buckets map looking for stale entries, so the sweep itself doesn't
+// turn every call into an O(map size) operation.
+const rateLimiterSweepInterval = time.Minute
+
+// rateLimiterIdleTTLFactor sets how many refill windows (burst/rate,
+// the time to go from empty to full) a bucket can sit untouched before
+// Allow prunes it.
+const rateLimiterIdleTTLFactor = 10
+
+// RateLimiter is a token-bucket rate limiter keyed by an arbitrary
+// string (e.g. a client IP, header value, or path), for a directive
+// implementing per-key request throttling. Buckets untouched for
+// several refill windows are pruned automatically, so a public site
+// keyed by client IP doesn't grow this map for the life of the
+// process. The zero value is ready to use.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+	sweep   sweepGate
+}
+
+// rateBucket tracks the remaining tokens for one key and when it was
+// last refilled.
+type rateBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// Allow reports whether a request keyed by key is allowed at now,
+// given a bucket that refills at rate tokens per second up to burst
+// tokens. If allowed, one token is consumed. A directive calls this
+// once per request with the same rate/burst it was configured with,
+// and returns a 429 with Retry-After when it returns false.
+func (r *RateLimiter) Allow(key string, rate float64, burst int, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.buckets == nil {
+		r.buckets = make(map[string]*rateBucket)
+	}
+
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = &rateBucket{tokens: float64(burst), lastRefill: now}
+		r.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.lastRefill).Seconds()
+	if elapsed > 0 {
+		bucket.tokens += elapsed * rate
+		if bucket.tokens > float64(burst) {
+			bucket.tokens = float64(burst)
+		}
+		bucket.lastRefill = now
+	}
+
+	if r.sweep.due(now, rateLimiterSweepInterval) {
+		ttl := rateLimiterIdleTTL(rate, burst)
+		for k, b := range r.buckets {
+			if now.Sub(b.lastRefill) > ttl {
+				delete(r.buckets, k)
+			}
+		}
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// rateLimiterIdleTTL returns how long a bucket may sit untouched
+// before it's considered stale, scaled to the rate/burst it was last
+// used with so a slow, bursty limiter isn't pruned mid-refill.
+func rateLimiterIdleTTL(rate float64, burst int) time.Duration {
+	if rate <= 0 {
+		return time.Hour
+	}
+	refillWindow := time.Duration(float64(burst) / rate * float64(time.Second))
+	if ttl := refillWindow * rateLimiterIdleTTLFactor; ttl > time.Minute {
+		return ttl
+	}
+	return time.Minute
+}
+
+// RateLimitByIP reports whether the current request's ClientIP is
+// allowed under limiter's rate/burst configuration, for a `ratelimit`
+// directive keying by client address.
+func (c Context) RateLimitByIP(limiter *RateLimiter, rate float64, burst int) bool {
+	return limiter.Allow(c.ClientIP(), rate, burst, c.Now())
+}
+
+// RateLimitByHeader behaves like RateLimitByIP, but keys the bucket on
+// the value of the named request header instead (e.g. an API key),
+// for keying strategies other than client address.
+func (c Context) RateLimitByHeader(limiter *RateLimiter, name string, rate float64, burst int) bool {
+	return limiter.Allow(c.Header(name), rate, burst, c.Now())
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedirectMapEntry is one row of a RedirectMap: the destination
+// Target and status Code to redirect an old path to.
+type RedirectMapEntry struct {
+	Target string
+	Code   int
+}
+
+// RedirectMap looks up a redirect target by request path in O(1),
+// loaded from a flat file (one "old-path new-path [status]" row per
+// line, whitespace-separated; blank lines and "#"-prefixed lines are
+// ignored; status defaults to http.StatusMovedPermanently if
+// omitted), for a `redir` directive migrating tens of thousands of
+// legacy URLs without one rule per path. It reloads the file
+// automatically whenever its mtime changes, the same hot-reload
+// approach templateCache uses elsewhere in this package. The
+// zero value is ready to use once Path is set.
+type RedirectMap struct {
+	// Path is the map file's location, resolved against the
+	// http.FileSystem passed to Lookup.
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	entries map[string]RedirectMapEntry
+}
+
+// Lookup returns the RedirectMapEntry for requestPath, reloading m's
+// backing file from root first if its mtime has changed since the
+// last load (or if it hasn't been loaded yet).
+func (m *RedirectMap) Lookup(root http.FileSystem, requestPath string) (RedirectMapEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.reloadIfChanged(root); err != nil {
+		return RedirectMapEntry{}, false
+	}
+	entry, ok := m.entries[requestPath]
+	return entry, ok
+}
+
+// reloadIfChanged reparses m.Path from root if its mtime has moved
+// past m.modTime, or if m.entries hasn't been populated yet.
+func (m *RedirectMap) reloadIfChanged(root http.FileSystem) error {
+	file, err := root.Open(m.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if m.entries != nil && info.ModTime().Equal(m.modTime) {
+		return nil
+	}
+
+	entries := make(map[string]RedirectMapEntry)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		code := http.StatusMovedPermanently
+		if len(fields) >= 3 {
+			if parsed, err := strconv.Atoi(fields[2]); err == nil {
+				code = parsed
+			}
+		}
+		entries[fields[0]] = RedirectMapEntry{Target: fields[1], Code: code}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	m.entries = entries
+	m.modTime = info.ModTime()
+	return nil
+}
+
+// RedirectFromMap looks up the current request's path in m and, if
+// found, issues the mapped redirect via Redirect. Like RedirectRegex,
+// if the path isn't in the map, no redirect happens and both return
+// values are zero, so a directive falls through to normal handling.
+func (c Context) RedirectFromMap(m *RedirectMap) (string, error) {
+	entry, ok := m.Lookup(c.Root, c.Req.URL.Path)
+	if !ok {
+		return "", nil
+	}
+	return c.Redirect(entry.Target, entry.Code)
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRenderQueueTimeout is returned by Markdown, MarkdownWithOptions,
+// and Include when c.RenderLimiter is set and no render slot became
+// free within c.RenderTimeout.
+var ErrRenderQueueTimeout = errors.New("middleware: timed out waiting for a render slot")
+
+// RenderSemaphore bounds how many Markdown and top-level Include
+// renders run concurrently, so a burst of uncached large-document
+// requests can't spike memory unboundedly. Construct one with
+// NewRenderSemaphore and share it across a site's Contexts by setting
+// RenderLimiter; the zero value (a nil *RenderSemaphore) leaves
+// rendering unbounded.
+type RenderSemaphore struct {
+	tokens chan struct{}
+}
+
+// NewRenderSemaphore returns a RenderSemaphore allowing up to limit
+// concurrent renders.
+func NewRenderSemaphore(limit int) *RenderSemaphore {
+	return &RenderSemaphore{tokens: make(chan struct{}, limit)}
+}
+
+// acquire blocks until a slot is free or timeout elapses, returning a
+// function that releases the slot. A nil RenderSemaphore has no
+// limit, so it always succeeds without blocking.
+func (s *RenderSemaphore) acquire(timeout time.Duration) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case s.tokens <- struct{}{}:
+		return func() { <-s.tokens }, nil
+	case <-time.After(timeout):
+		return nil, ErrRenderQueueTimeout
+	}
+}
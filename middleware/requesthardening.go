@@ -0,0 +1,46 @@
+package middleware
+
+import "errors"
+
+// ErrTooManyHeaders is returned by ValidateHeaderLimits when a request
+// carries more header values than its maxCount allows.
+var ErrTooManyHeaders = errors.New("middleware: request has too many headers")
+
+// ErrHeadersTooLarge is returned by ValidateHeaderLimits when a
+// request's combined header name and value bytes exceed its maxBytes.
+var ErrHeadersTooLarge = errors.New("middleware: request headers exceed the configured size limit")
+
+// ValidateHeaderLimits rejects the current request if it carries more
+// than maxCount header values (summed across all names, since
+// duplicating one header many times is as much a resource-exhaustion
+// vector as having many distinct ones) or more than maxBytes of header
+// name+value data combined. Either limit set to 0 disables that check.
+//
+// This is a second, app-level line of defense, not a full smuggling
+// mitigation: Go's own HTTP server already enforces
+// http.Server.MaxHeaderBytes at the listener before a request reaches
+// this package, and already rejects a request with conflicting
+// Content-Length/Transfer-Encoding headers or obs-fold (line-folded)
+// header continuations during parsing — by the time c.Req exists, both
+// are already resolved, and there's no wire-level detail left here to
+// re-validate. Configuring either of those, or a listener-level header
+// cap, is an http.Server/tls.Config concern this middleware-only
+// package has no access to.
+func (c Context) ValidateHeaderLimits(maxCount, maxBytes int) error {
+	count := 0
+	size := 0
+	for name, values := range c.Req.Header {
+		for _, value := range values {
+			count++
+			size += len(name) + len(value)
+		}
+	}
+
+	if maxCount > 0 && count > maxCount {
+		return ErrTooManyHeaders
+	}
+	if maxBytes > 0 && size > maxBytes {
+		return ErrHeadersTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// ResponseWriterWrapper wraps an http.ResponseWriter so that further
+// wrapping (e.g. a gzip or logging writer) doesn't hide the Flush,
+// Hijack, and CloseNotify methods the underlying writer supports. Its
+// own Flush/Hijack/CloseNotify methods delegate to the underlying
+// writer when it implements the corresponding interface, and are safe,
+// low-cost no-ops (or an error, for Hijack) otherwise. This means a
+// type assertion against http.Flusher/http.Hijacker/http.CloseNotifier
+// always succeeds on a *ResponseWriterWrapper, so callers should check
+// the return value of Hijack rather than relying on the failed
+// assertion they'd get from an unwrapped writer.
+type ResponseWriterWrapper struct {
+	http.ResponseWriter
+}
+
+// WrapResponseWriter returns rw wrapped in a *ResponseWriterWrapper, or
+// rw itself if it's already wrapped.
+func WrapResponseWriter(rw http.ResponseWriter) *ResponseWriterWrapper {
+	if w, ok := rw.(*ResponseWriterWrapper); ok {
+		return w
+	}
+	return &ResponseWriterWrapper{ResponseWriter: rw}
+}
+
+// Flush flushes buffered data to the client if the underlying writer
+// supports it, and is a no-op otherwise.
+func (w *ResponseWriterWrapper) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection if the underlying writer supports
+// it, and returns http.ErrNotSupported otherwise.
+func (w *ResponseWriterWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// CloseNotify returns a channel that closes when the underlying
+// connection closes, if the underlying writer supports it, and a
+// channel that never fires otherwise.
+func (w *ResponseWriterWrapper) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// Push initiates an HTTP/2 server push if the underlying writer
+// supports it, and returns http.ErrNotSupported otherwise.
+func (w *ResponseWriterWrapper) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"errors"
+)
+
+// RevocationPolicy controls how CertificateRevoked responds when
+// revocation status can't be determined at all (no CRL loaded, or no
+// client certificate on the request).
+type RevocationPolicy int
+
+const (
+	// RevocationSoftFail treats an undeterminable status as not
+	// revoked, so a stale or not-yet-loaded CRL doesn't take down mTLS
+	// entirely.
+	RevocationSoftFail RevocationPolicy = iota
+	// RevocationHardFail treats an undeterminable status as revoked,
+	// for deployments where an unverifiable certificate must never be
+	// let through.
+	RevocationHardFail
+)
+
+// ErrCertificateRevoked is returned by CertificateRevoked when the
+// request's client certificate serial number appears on the supplied
+// CRL, or when policy is RevocationHardFail and revocation status
+// couldn't be determined.
+var ErrCertificateRevoked = errors.New("middleware: client certificate has been revoked")
+
+// CertificateRevoked checks the current request's client certificate
+// (see ClientCertificate) against crl, an already-parsed CRL such as
+// one returned by x509.ParseRevocationList. The caller is expected to
+// re-parse and swap crl on a timer or file-watch to keep it current;
+// this function only evaluates whatever is handed to it. If the
+// request presented no client certificate, or crl is nil, the outcome
+// is governed by policy instead of being evaluated against the list.
+//
+// Checking an OCSP responder instead of a CRL isn't implemented here:
+// doing so correctly means encoding an OCSP request and verifying a
+// signed OCSP response (RFC 6960) over the network, which is a
+// client-library job (e.g. golang.org/x/crypto/ocsp) rather than
+// something to hand-roll in a dependency-free middleware package. CRL
+// support answers the same "has this certificate been revoked"
+// question for deployments that can supply one.
+func (c Context) CertificateRevoked(crl *x509.RevocationList, policy RevocationPolicy) error {
+	cert := c.ClientCertificate()
+	if cert == nil || crl == nil {
+		if policy == RevocationHardFail {
+			return ErrCertificateRevoked
+		}
+		return nil
+	}
+
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber != nil && cert.SerialNumber != nil && entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return ErrCertificateRevoked
+		}
+	}
+	return nil
+}
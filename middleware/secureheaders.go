@@ -0,0 +1,86 @@
+package middleware
+
+import "strconv"
+
+// secureHeaderDefaults are the static headers SecureHeaders sets when
+// the corresponding option isn't used to override or drop them. HSTS
+// is built separately by secureHeaderConfig, since its preload
+// attribute composes with max-age rather than replacing it.
+var secureHeaderDefaults = map[string]string{
+	"X-Content-Type-Options": "nosniff",
+	"X-Frame-Options":        "DENY",
+	"Referrer-Policy":        "strict-origin-when-cross-origin",
+}
+
+// secureHeaderConfig accumulates SecureHeaderOption settings before
+// SecureHeaders renders them into concrete header values, so options
+// can be given in any order without one clobbering another (e.g.
+// SecureHeaderHSTSPreload then SecureHeaderHSTSMaxAge).
+type secureHeaderConfig struct {
+	hstsMaxAgeSeconds int
+	hstsPreload       bool
+	headers           map[string]string
+}
+
+// SecureHeaderOption configures the preset SecureHeaders applies.
+type SecureHeaderOption func(*secureHeaderConfig)
+
+// SecureHeaderHSTSPreload adds the "preload" directive to the
+// Strict-Transport-Security header, for a site submitted to browsers'
+// HSTS preload lists.
+func SecureHeaderHSTSPreload() SecureHeaderOption {
+	return func(cfg *secureHeaderConfig) { cfg.hstsPreload = true }
+}
+
+// SecureHeaderHSTSMaxAge overrides the Strict-Transport-Security
+// max-age, in seconds.
+func SecureHeaderHSTSMaxAge(seconds int) SecureHeaderOption {
+	return func(cfg *secureHeaderConfig) { cfg.hstsMaxAgeSeconds = seconds }
+}
+
+// SecureHeaderCSP sets a Content-Security-Policy header, which
+// secureHeaderDefaults omits since a safe default policy depends too
+// much on the site's own scripts and styles to guess.
+func SecureHeaderCSP(policy string) SecureHeaderOption {
+	return func(cfg *secureHeaderConfig) { cfg.headers["Content-Security-Policy"] = policy }
+}
+
+// SecureHeaderOverride sets name to value, or removes name entirely if
+// value is empty, for overriding or dropping any header
+// secureHeaderDefaults would otherwise set.
+func SecureHeaderOverride(name, value string) SecureHeaderOption {
+	return func(cfg *secureHeaderConfig) {
+		if value == "" {
+			delete(cfg.headers, name)
+			return
+		}
+		cfg.headers[name] = value
+	}
+}
+
+// SecureHeaders sets a curated preset of hardening response headers
+// (HSTS, X-Content-Type-Options, X-Frame-Options, Referrer-Policy),
+// so a site doesn't need to copy the same header block into every
+// Caddyfile. opts customize or remove individual headers, e.g.
+// SecureHeaderHSTSPreload, SecureHeaderCSP, or
+// SecureHeaderOverride("X-Frame-Options", "SAMEORIGIN").
+func (c Context) SecureHeaders(opts ...SecureHeaderOption) string {
+	cfg := &secureHeaderConfig{hstsMaxAgeSeconds: 31536000, headers: make(map[string]string, len(secureHeaderDefaults))}
+	for name, value := range secureHeaderDefaults {
+		cfg.headers[name] = value
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	hsts := "max-age=" + strconv.Itoa(cfg.hstsMaxAgeSeconds)
+	if cfg.hstsPreload {
+		hsts += "; preload"
+	}
+	cfg.headers["Strict-Transport-Security"] = hsts
+
+	for name, value := range cfg.headers {
+		c.ResponseWriter.Header().Set(name, value)
+	}
+	return ""
+}
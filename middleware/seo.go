@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"html"
+	"path"
+	"strings"
+)
+
+// Robots renders a robots.txt body allowing all user agents except for
+// the given disallow paths, plus any path matching c.HidePatterns, so
+// hidden/internal files aren't crawled even if the Caddyfile's
+// disallow list doesn't mention them explicitly.
+func (c Context) Robots(disallow ...string) string {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	for _, d := range disallow {
+		b.WriteString("Disallow: " + d + "\n")
+	}
+	for _, pattern := range c.HidePatterns {
+		b.WriteString("Disallow: /" + pattern + "\n")
+	}
+	return b.String()
+}
+
+// Sitemap walks the site's file tree and renders a sitemap.xml listing
+// every .html/.htm file as an absolute URL under baseURL, skipping
+// entries hidden by c.HidePatterns.
+func (c Context) Sitemap(baseURL string) (string, error) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+
+	if err := c.writeSitemapURLs(".", baseURL, &b); err != nil {
+		return "", err
+	}
+
+	b.WriteString("</urlset>\n")
+	return b.String(), nil
+}
+
+// writeSitemapURLs recursively visits dirPath, relative to the site
+// root, writing a <url> entry for every non-hidden HTML file it finds.
+func (c Context) writeSitemapURLs(dirPath, baseURL string, b *strings.Builder) error {
+	entries, err := c.Files(dirPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if c.isHidden(entry.Name()) {
+			continue
+		}
+
+		childPath := path.Join(dirPath, entry.Name())
+
+		if entry.IsDir() {
+			if err := c.writeSitemapURLs(childPath, baseURL, b); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !MatchesExtension(entry.Name(), []string{".html", ".htm"}) {
+			continue
+		}
+
+		webPath := "/" + strings.TrimPrefix(childPath, "./")
+		b.WriteString("  <url><loc>" + html.EscapeString(baseURL+webPath) + "</loc></url>\n")
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionCookie is the cookie Session stores its session ID (or, for
+// the default stateless store, its whole signed payload) under.
+const SessionCookie = "caddy_session"
+
+// SessionStore persists session data server-side, keyed by a session
+// ID. If Context.Session is called with a nil store, session data is
+// instead kept entirely in a signed cookie rather than server-side, so
+// most single-instance sites need no store at all.
+type SessionStore interface {
+	// Load returns the session data for id, and whether it was found.
+	Load(id string) (map[string]interface{}, bool)
+	// Save persists data under id.
+	Save(id string, data map[string]interface{})
+}
+
+// sessionSweepInterval bounds how often Save walks the whole data map
+// looking for stale sessions, so the sweep itself doesn't turn every
+// call into an O(map size) operation.
+const sessionSweepInterval = time.Minute
+
+// sessionIdleTTL is how long a session may go untouched by both Load
+// and Save before MemorySessionStore prunes it. It's independent of,
+// and well beyond, any SessionMaxAge a directive configures for the
+// cookie itself, which only governs the client's copy.
+const sessionIdleTTL = 24 * time.Hour
+
+// sessionEntry holds one session's data and when it was last read or
+// written.
+type sessionEntry struct {
+	data     map[string]interface{}
+	lastSeen time.Time
+}
+
+// MemorySessionStore is a SessionStore backed by an in-process map, for
+// sites that want server-side sessions without a database, at the cost
+// of losing all sessions on restart and not sharing them across
+// instances. Sessions untouched for sessionIdleTTL are pruned
+// automatically, so a site that never expires its cookies (no
+// SessionMaxAge) doesn't grow this map for the life of the process.
+type MemorySessionStore struct {
+	mu    sync.Mutex
+	data  map[string]*sessionEntry
+	sweep sweepGate
+}
+
+// NewMemorySessionStore returns an empty MemorySessionStore ready to
+// use.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{data: make(map[string]*sessionEntry)}
+}
+
+// Load implements SessionStore.
+func (s *MemorySessionStore) Load(id string) (map[string]interface{}, bool) {
+	return s.load(id, time.Now())
+}
+
+// load is the testable core of Load, taking now explicitly the way
+// RateLimiter.Allow and Banner.RecordFailure do.
+func (s *MemorySessionStore) load(id string, now time.Time) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.data[id]
+	if !ok {
+		return nil, false
+	}
+	entry.lastSeen = now
+	return entry.data, true
+}
+
+// Save implements SessionStore.
+func (s *MemorySessionStore) Save(id string, data map[string]interface{}) {
+	s.save(id, data, time.Now())
+}
+
+// save is the testable core of Save, taking now explicitly the way
+// RateLimiter.Allow and Banner.RecordFailure do.
+func (s *MemorySessionStore) save(id string, data map[string]interface{}, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[id] = &sessionEntry{data: data, lastSeen: now}
+
+	if s.sweep.due(now, sessionSweepInterval) {
+		for k, e := range s.data {
+			if now.Sub(e.lastSeen) > sessionIdleTTL {
+				delete(s.data, k)
+			}
+		}
+	}
+}
+
+// SessionOption configures the session cookie Session sets, and, for
+// signed-cookie sessions, which keys it accepts.
+type SessionOption func(*sessionConfig)
+
+// sessionConfig accumulates SessionOption settings for one Session
+// call.
+type sessionConfig struct {
+	maxAgeSeconds int
+	sameSite      http.SameSite
+	oldSecrets    []string
+}
+
+// SessionMaxAge sets the session cookie's lifetime, in seconds. With
+// no SessionMaxAge, the cookie has no Max-Age and expires when the
+// browser session ends.
+func SessionMaxAge(seconds int) SessionOption {
+	return func(cfg *sessionConfig) { cfg.maxAgeSeconds = seconds }
+}
+
+// SessionSameSite sets the session cookie's SameSite attribute.
+func SessionSameSite(mode http.SameSite) SessionOption {
+	return func(cfg *sessionConfig) { cfg.sameSite = mode }
+}
+
+// SessionOldSecrets adds secrets that a signed-cookie session's
+// existing HMAC is also checked against, so a signing key can be
+// rotated without invalidating sessions signed under the previous
+// one: new sessions are always signed with Session's secret argument,
+// but a cookie signed with any of oldSecrets is still accepted (and,
+// on its next Set, re-signed with the current secret) until it
+// expires on its own.
+func SessionOldSecrets(secrets ...string) SessionOption {
+	return func(cfg *sessionConfig) { cfg.oldSecrets = secrets }
+}
+
+// sessionCookieOpts translates cfg into the CookieOptions SetCookie
+// needs to apply it.
+func sessionCookieOpts(cfg *sessionConfig) []CookieOption {
+	var opts []CookieOption
+	if cfg.maxAgeSeconds != 0 {
+		opts = append(opts, CookieMaxAge(cfg.maxAgeSeconds))
+	}
+	if cfg.sameSite != http.SameSiteDefaultMode {
+		opts = append(opts, CookieSameSite(cfg.sameSite))
+	}
+	return opts
+}
+
+// Session is a per-request session, read and written through Get and
+// Set, e.g. as {{.Session.Get "user"}} and {{$s := .Session}}
+// {{$s.Set "user" "alice"}} in a template. Each Set immediately
+// persists the change, either to the backing SessionStore or, with no
+// store, back into the signed session cookie.
+type Session struct {
+	c      Context
+	id     string
+	secret string
+	store  SessionStore
+	data   map[string]interface{}
+	cfg    *sessionConfig
+}
+
+// Get returns the session value stored under key, or nil if it isn't
+// set.
+func (s *Session) Get(key string) interface{} {
+	return s.data[key]
+}
+
+// Set stores value under key and persists the session.
+func (s *Session) Set(key string, value interface{}) {
+	s.data[key] = value
+	s.save()
+}
+
+// Delete removes key from the session and persists the change.
+func (s *Session) Delete(key string) {
+	delete(s.data, key)
+	s.save()
+}
+
+func (s *Session) save() {
+	opts := sessionCookieOpts(s.cfg)
+
+	if s.store != nil {
+		s.store.Save(s.id, s.data)
+		s.c.SetCookie(SessionCookie, s.id, opts...)
+		return
+	}
+
+	encoded, err := encodeSignedSession(s.data, s.secret)
+	if err != nil {
+		return
+	}
+	s.c.SetCookie(SessionCookie, encoded, opts...)
+}
+
+// Session returns the current request's session: if store is non-nil,
+// data is looked up server-side by a random session ID kept in
+// SessionCookie; if store is nil, the session data itself is kept
+// (JSON-encoded and HMAC-SHA256 signed with secret) in SessionCookie,
+// so a single-instance site needs no server-side storage at all. opts
+// configures the session cookie's lifetime and SameSite attribute
+// (SessionMaxAge, SessionSameSite) and, for a signed-cookie session,
+// additional signing keys still accepted during a rotation
+// (SessionOldSecrets).
+func (c Context) Session(secret string, store SessionStore, opts ...SessionOption) (*Session, error) {
+	cfg := &sessionConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if store != nil {
+		id := c.Cookie(SessionCookie)
+		if id == "" {
+			var err error
+			id, err = randomHexID(16)
+			if err != nil {
+				return nil, err
+			}
+		}
+		c.SetCookie(SessionCookie, id, sessionCookieOpts(cfg)...)
+
+		data, ok := store.Load(id)
+		if !ok {
+			data = make(map[string]interface{})
+		}
+		return &Session{c: c, id: id, secret: secret, store: store, data: data, cfg: cfg}, nil
+	}
+
+	data := make(map[string]interface{})
+	if raw := c.Cookie(SessionCookie); raw != "" {
+		if decoded, ok := decodeSignedSession(raw, secret); ok {
+			data = decoded
+		} else {
+			for _, old := range cfg.oldSecrets {
+				if decoded, ok := decodeSignedSession(raw, old); ok {
+					data = decoded
+					break
+				}
+			}
+		}
+	}
+	return &Session{c: c, secret: secret, data: data, cfg: cfg}, nil
+}
+
+// encodeSignedSession JSON-encodes data, base64-encodes it, and
+// appends a hex HMAC-SHA256 signature over the encoded payload with
+// secret, separated by a ".", for storing session data directly in a
+// cookie without server-side state.
+func encodeSignedSession(data map[string]interface{}, secret string) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	return payload + "." + signature, nil
+}
+
+// decodeSignedSession verifies and decodes a cookie value produced by
+// encodeSignedSession, returning ok=false if the signature doesn't
+// match secret or the payload can't be decoded.
+func decodeSignedSession(cookie, secret string) (map[string]interface{}, bool) {
+	dot := len(cookie) - 65 // 64 hex chars + "."
+	if dot < 0 || cookie[dot] != '.' {
+		return nil, false
+	}
+	payload, signature := cookie[:dot], cookie[dot+1:]
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, false
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, false
+	}
+	return data, true
+}
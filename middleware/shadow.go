@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+)
+
+// ShadowRequest asynchronously duplicates the current request to
+// targetURL and discards its response, for testing a new backend
+// version against a sample of production traffic without it affecting
+// what's actually served to the client. percent (0-100) controls how
+// often a request is mirrored; the decision is made independently per
+// request, so there's no session affinity between a client's repeated
+// requests. It returns ErrEgressDenied without mirroring if
+// targetURL's host is blocked by c.EgressPolicy, and otherwise
+// returns immediately without waiting for the mirrored request to
+// complete, using httpIncludeClient's timeout to bound it.
+func (c Context) ShadowRequest(targetURL string, percent float64) error {
+	if rand.Float64()*100 >= percent {
+		return nil
+	}
+	if err := checkEgress(c.EgressPolicy, targetURL); err != nil {
+		return err
+	}
+
+	var body []byte
+	if c.Req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(c.Req.Body)
+		if err != nil {
+			return err
+		}
+		c.Req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	req, err := http.NewRequest(c.Req.Method, targetURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = c.Req.Header.Clone()
+
+	go func() {
+		resp, err := httpIncludeClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+)
+
+// ServeFallback serves fallbackFile (opened via c.Root, e.g.
+// "/index.html") directly with a 200 status when the current request
+// path doesn't exist on disk, for a `fallback` directive supporting
+// SPA/history-mode routing (React, Vue, etc.), where any unmatched
+// path must render the app shell rather than 404 or bounce through a
+// TryFiles rewrite chain that a client-side router would then have to
+// unwind. If the current request path exists (per c.FileExists), it
+// returns false and writes nothing, so the caller falls through to
+// normal file serving. contentType, if set, is written as the
+// response's Content-Type; leave it empty to let the caller (or a
+// downstream directive) decide.
+func (c Context) ServeFallback(fallbackFile, contentType string) (bool, error) {
+	if c.FileExists(c.Req.URL.Path) {
+		return false, nil
+	}
+
+	file, err := c.Root.Open(fallbackFile)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	if contentType != "" {
+		c.ResponseWriter.Header().Set("Content-Type", contentType)
+	}
+	c.ResponseWriter.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(c.ResponseWriter, file); err != nil {
+		return true, err
+	}
+	return true, nil
+}
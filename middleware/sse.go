@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSSEUnsupported is returned by ServeSSE when the response writer
+// doesn't support flushing, which server-sent events need to deliver
+// each event as it happens rather than buffering the whole response.
+var ErrSSEUnsupported = errors.New("middleware: response writer does not support flushing, required for SSE")
+
+// SSEEvent is one server-sent event: ID for Last-Event-ID replay
+// (assigned by SSEBroker.Publish, overwriting whatever the caller
+// set), Name for the optional "event:" field, and Data for the
+// "data:" field (split across multiple "data:" lines if it contains
+// newlines, per the SSE spec).
+type SSEEvent struct {
+	ID   int64
+	Name string
+	Data string
+}
+
+// SSEBroker fans a stream of SSEEvents out to any number of connected
+// clients and keeps the most recent events in a ring buffer so a
+// reconnecting client can replay what it missed via Last-Event-ID,
+// for an `sse` directive's event-stream endpoint. Construct one with
+// NewSSEBroker; the zero value has no capacity to buffer replay
+// events.
+type SSEBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan SSEEvent]struct{}
+	buffer      []SSEEvent
+	capacity    int
+	nextID      int64
+}
+
+// NewSSEBroker returns an SSEBroker that replays up to capacity of
+// its most recent events to a reconnecting client.
+func NewSSEBroker(capacity int) *SSEBroker {
+	return &SSEBroker{subscribers: make(map[chan SSEEvent]struct{}), capacity: capacity}
+}
+
+// Publish assigns event the next sequential ID, appends it to the
+// ring buffer, and delivers it to every currently subscribed client.
+// A subscriber whose channel is full is skipped for this event rather
+// than blocking the publisher, so one slow client can't stall the
+// rest.
+func (b *SSEBroker) Publish(event SSEEvent) {
+	b.mu.Lock()
+	b.nextID++
+	event.ID = b.nextID
+	b.buffer = append(b.buffer, event)
+	if b.capacity > 0 && len(b.buffer) > b.capacity {
+		b.buffer = b.buffer[len(b.buffer)-b.capacity:]
+	}
+
+	subscribers := make([]chan SSEEvent, 0, len(b.subscribers))
+	for ch := range b.subscribers {
+		subscribers = append(subscribers, ch)
+	}
+	b.mu.Unlock()
+
+	for _, ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// subscribe registers a new client channel and returns the buffered
+// events after lastEventID (all of them if lastEventID is 0 or older
+// than the buffer's oldest retained event) for ServeSSE to replay
+// before the client starts receiving live events.
+func (b *SSEBroker) subscribe(lastEventID int64) (chan SSEEvent, []SSEEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan SSEEvent, 16)
+	b.subscribers[ch] = struct{}{}
+
+	var replay []SSEEvent
+	for _, event := range b.buffer {
+		if event.ID > lastEventID {
+			replay = append(replay, event)
+		}
+	}
+	return ch, replay
+}
+
+// unsubscribe removes ch, for when a client disconnects.
+func (b *SSEBroker) unsubscribe(ch chan SSEEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, ch)
+}
+
+// ServeSSE serves the current request as a server-sent event stream
+// from broker: it sets the text/event-stream headers, replays
+// whatever broker has buffered after the request's Last-Event-ID
+// header, then blocks, writing each newly published event as it
+// arrives and a keep-alive comment every keepAlive interval, until the
+// request's context is done (the client disconnects). A directive
+// registers one SSEBroker per event-stream endpoint and calls this
+// for every client connection.
+func (c Context) ServeSSE(broker *SSEBroker, keepAlive time.Duration) error {
+	flusher, ok := c.ResponseWriter.(http.Flusher)
+	if !ok {
+		return ErrSSEUnsupported
+	}
+
+	lastEventID, _ := strconv.ParseInt(c.Header("Last-Event-ID"), 10, 64)
+	ch, replay := broker.subscribe(lastEventID)
+	defer broker.unsubscribe(ch)
+
+	c.ResponseWriter.Header().Set("Content-Type", "text/event-stream")
+	c.ResponseWriter.Header().Set("Cache-Control", "no-cache")
+	c.ResponseWriter.Header().Set("Connection", "keep-alive")
+	c.ResponseWriter.WriteHeader(http.StatusOK)
+
+	for _, event := range replay {
+		if err := writeSSEEvent(c.ResponseWriter, event); err != nil {
+			return err
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Req.Context().Done():
+			return nil
+		case event := <-ch:
+			if err := writeSSEEvent(c.ResponseWriter, event); err != nil {
+				return err
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := io.WriteString(c.ResponseWriter, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// IngestSSEEvent reads the current request's body as the Data of a
+// new SSEEvent (with Name taken from the X-Event-Name header, if set)
+// and publishes it to broker, for an `sse` directive's HTTP ingest
+// endpoint. Authenticating who may publish is a directive-level
+// concern, the same as guarding any other endpoint with
+// VerifyHMACSignature or similar.
+func (c Context) IngestSSEEvent(broker *SSEBroker) error {
+	body, err := c.readWebhookBody()
+	if err != nil {
+		return err
+	}
+	broker.Publish(SSEEvent{Name: c.Header("X-Event-Name"), Data: string(body)})
+	return nil
+}
+
+// writeSSEEvent writes event to w in the wire format the EventSource
+// API expects: an optional "id:" line, an optional "event:" line, one
+// "data:" line per line of event.Data, and a trailing blank line.
+func writeSSEEvent(w io.Writer, event SSEEvent) error {
+	var b strings.Builder
+	if event.ID != 0 {
+		fmt.Fprintf(&b, "id: %d\n", event.ID)
+	}
+	if event.Name != "" {
+		fmt.Fprintf(&b, "event: %s\n", event.Name)
+	}
+	for _, line := range strings.Split(event.Data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrUpstreamIPDenied is returned by ResolveUpstreamIP when every
+// address host resolves to is blocked by its denied CIDR ranges.
+var ErrUpstreamIPDenied = errors.New("middleware: upstream resolved to a denied address")
+
+// defaultDeniedCIDRs are always checked by ResolveUpstreamIP: nothing
+// but the machine Caddy runs on should ever need to reach its own
+// link-local metadata endpoint (e.g. the cloud-provider-standard
+// 169.254.169.254) or loopback through a proxied request.
+var defaultDeniedCIDRs = []string{
+	"169.254.0.0/16",
+	"127.0.0.0/8",
+	"::1/128",
+	"fe80::/10",
+}
+
+// PrivateNetworkCIDRs are the RFC1918/RFC4193 private ranges
+// ResolveUpstreamIP does NOT block by default, since a legitimate
+// internal proxy target often lives in one; a directive with a
+// dynamic or placeholder-derived upstream host opts into blocking
+// them by passing denyPrivateNetworks.
+var PrivateNetworkCIDRs = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+}
+
+// ResolveUpstreamIP resolves host and returns the first address that
+// isn't blocked by defaultDeniedCIDRs (plus PrivateNetworkCIDRs, if
+// denyPrivateNetworks is set), or ErrUpstreamIPDenied if every
+// resolved address is blocked. A directive with a dynamic upstream
+// host (DNS-based, or resolved via ResolveUpstreamFromHeader) dials
+// the returned IP directly instead of letting the transport re-resolve
+// host, so a second DNS lookup mid-request can't swap in a different,
+// unvalidated address — the DNS rebinding attack a hostname-only
+// allowlist check (like EgressPolicy's) can't catch on its own.
+func (c Context) ResolveUpstreamIP(host string, denyPrivateNetworks bool) (net.IP, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+
+	denied := defaultDeniedCIDRs
+	if denyPrivateNetworks {
+		denied = append(append([]string{}, defaultDeniedCIDRs...), PrivateNetworkCIDRs...)
+	}
+
+	for _, ip := range ips {
+		if !ipInAnyCIDR(ip, denied) {
+			return ip, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUpstreamIPDenied, host)
+}
+
+// ipInAnyCIDR reports whether ip falls within any of cidrs. An entry
+// that fails to parse is skipped rather than aborting the check.
+func ipInAnyCIDR(ip net.IP, cidrs []string) bool {
+	for _, raw := range cidrs {
+		_, network, err := net.ParseCIDR(raw)
+		if err != nil {
+			continue
+		}
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// statCacheEntry holds a cached os.Stat result together with when it
+// expires.
+type statCacheEntry struct {
+	info    os.FileInfo
+	err     error
+	expires time.Time
+}
+
+// StatCache caches c.Root.Open+Stat results for a TTL, the way
+// HostnameCache caches reverse DNS lookups, so a hot file (a favicon,
+// a shared partial) requested on every request doesn't cost a fresh
+// open/stat syscall pair each time. It doesn't keep the file open
+// between requests or watch it for changes: Go has no portable
+// inotify-style API without an external dependency, so a file
+// modified within the TTL window can serve stale info briefly; a
+// directive should pick a TTL it's comfortable with (or 0 to disable
+// caching). The zero value is ready to use.
+type StatCache struct {
+	mu      sync.Mutex
+	entries map[string]statCacheEntry
+}
+
+// stat returns cached info for name if it was fetched within ttl of
+// now, otherwise opens and stats it fresh through c.Root and caches
+// the result.
+func (s *StatCache) stat(c Context, name string, now time.Time, ttl time.Duration) (os.FileInfo, error) {
+	s.mu.Lock()
+	if s.entries == nil {
+		s.entries = make(map[string]statCacheEntry)
+	}
+	if entry, ok := s.entries[name]; ok && now.Before(entry.expires) {
+		s.mu.Unlock()
+		return entry.info, entry.err
+	}
+	s.mu.Unlock()
+
+	var info os.FileInfo
+	file, err := c.Root.Open(name)
+	if err == nil {
+		info, err = file.Stat()
+		file.Close()
+	}
+
+	s.mu.Lock()
+	s.entries[name] = statCacheEntry{info: info, err: err, expires: now.Add(ttl)}
+	s.mu.Unlock()
+
+	return info, err
+}
+
+// CachedFileInfo returns os.Stat info for name under c.Root, reusing a
+// result already fetched within the last ttl instead of hitting the
+// filesystem again, for a directive that checks a hot file's size or
+// mtime (e.g. for an ETag or Last-Modified header) on every request.
+func (c Context) CachedFileInfo(cache *StatCache, name string, ttl time.Duration) (os.FileInfo, error) {
+	return cache.stat(c, name, c.Now(), ttl)
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// staticSiteMarkdownExtensions are the extensions GenerateStaticSite
+// renders with Markdown rather than Include.
+var staticSiteMarkdownExtensions = []string{".md", ".markdown"}
+
+// GenerateStaticSite walks the site root and writes a fully rendered
+// copy of it under destDir on the local filesystem, turning the site
+// into static output deployable to hosting with no template engine of
+// its own. Each ".html" file is rendered with Include, each ".md"/
+// ".markdown" file is rendered with Markdown and written with a
+// ".html" extension, and any other file (images, CSS, ...) is copied
+// unchanged. It returns the number of files written.
+func (c Context) GenerateStaticSite(destDir string) (int, error) {
+	written := 0
+	err := c.walkStaticSite(".", destDir, &written)
+	return written, err
+}
+
+// walkStaticSite recursively renders/copies the directory named
+// srcName, relative to the site root, into destDir on the local
+// filesystem, incrementing *written for each file produced.
+func (c Context) walkStaticSite(srcName, destDir string, written *int) error {
+	infos, err := c.Files(srcName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(destDir, c.dirMode()); err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		childSrc := path.Join(srcName, info.Name())
+		childDest := filepath.Join(destDir, info.Name())
+
+		if info.IsDir() {
+			if err := c.walkStaticSite(childSrc, childDest, written); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := c.renderStaticSiteFile(childSrc, childDest); err != nil {
+			return err
+		}
+		*written++
+	}
+
+	return nil
+}
+
+// renderStaticSiteFile renders or copies the single file at srcName
+// (relative to the site root) to destPath on the local filesystem, as
+// GenerateStaticSite documents.
+func (c Context) renderStaticSiteFile(srcName, destPath string) error {
+	switch {
+	case strings.EqualFold(filepath.Ext(srcName), ".html"):
+		content, err := c.Include(srcName)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(destPath, []byte(content), c.fileMode())
+
+	case MatchesExtension(srcName, staticSiteMarkdownExtensions):
+		content, err := c.Markdown(srcName)
+		if err != nil {
+			return err
+		}
+		destPath = strings.TrimSuffix(destPath, filepath.Ext(destPath)) + ".html"
+		return ioutil.WriteFile(destPath, []byte(content), c.fileMode())
+
+	default:
+		return c.copyStaticSiteFile(srcName, destPath)
+	}
+}
+
+// copyStaticSiteFile copies srcName (relative to the site root) to
+// destPath on the local filesystem unchanged.
+func (c Context) copyStaticSiteFile(srcName, destPath string) error {
+	src, err := c.Root.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, c.fileMode())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import "net/http"
+
+// StatusRecorder wraps an http.ResponseWriter to capture the status
+// code and byte count actually written, so logging, metrics, and
+// header middleware later in the same request chain can read what an
+// earlier handler already did (via StatusCode/BytesWritten) without a
+// type assertion against whatever concrete writer type happens to be
+// in play. Combined with Context.Vars for arbitrary custom
+// key-values, this covers the shared per-request state later
+// middleware needs. It embeds a *ResponseWriterWrapper so Flush,
+// Hijack, CloseNotify, and Push keep working through it.
+type StatusRecorder struct {
+	*ResponseWriterWrapper
+	StatusCode   int
+	BytesWritten int64
+}
+
+// NewStatusRecorder wraps w in a *StatusRecorder, or returns w
+// unchanged if it's already one.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	if r, ok := w.(*StatusRecorder); ok {
+		return r
+	}
+	return &StatusRecorder{ResponseWriterWrapper: WrapResponseWriter(w)}
+}
+
+// WriteHeader records code as StatusCode (the first call wins, same
+// as net/http itself) before delegating.
+func (r *StatusRecorder) WriteHeader(code int) {
+	if r.StatusCode == 0 {
+		r.StatusCode = code
+	}
+	r.ResponseWriterWrapper.WriteHeader(code)
+}
+
+// Write defaults StatusCode to 200 if nothing has called WriteHeader
+// yet, matching net/http's own behavior, records the number of bytes
+// written, then delegates.
+func (r *StatusRecorder) Write(p []byte) (int, error) {
+	if r.StatusCode == 0 {
+		r.StatusCode = http.StatusOK
+	}
+	n, err := r.ResponseWriterWrapper.Write(p)
+	r.BytesWritten += int64(n)
+	return n, err
+}
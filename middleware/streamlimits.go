@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// DeadlineConn wraps a hijacked net.Conn (from
+// ResponseWriterWrapper's Hijack, for a websocket or streaming proxy
+// directive), resetting an idle deadline on every Read and Write via
+// SetDeadline while never extending it past a hard ceiling at
+// maxDuration from when the connection was wrapped, regardless of
+// activity. A read or write past either limit returns the usual
+// os.ErrDeadlineExceeded-wrapping timeout error net.Conn callers
+// already know how to handle.
+type DeadlineConn struct {
+	net.Conn
+	idleTimeout  time.Duration
+	hardDeadline time.Time
+}
+
+// NewDeadlineConn wraps conn with idleTimeout and a hard ceiling of
+// maxDuration from now, per route, so a directive can bound how long
+// a hijacked connection stays open both when it's idle and in total.
+func NewDeadlineConn(conn net.Conn, idleTimeout, maxDuration time.Duration, now time.Time) *DeadlineConn {
+	return &DeadlineConn{Conn: conn, idleTimeout: idleTimeout, hardDeadline: now.Add(maxDuration)}
+}
+
+// Read applies the current idle/hard deadline before delegating to
+// the wrapped connection.
+func (c *DeadlineConn) Read(p []byte) (int, error) {
+	if err := c.applyDeadline(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// Write applies the current idle/hard deadline before delegating to
+// the wrapped connection.
+func (c *DeadlineConn) Write(p []byte) (int, error) {
+	if err := c.applyDeadline(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+// applyDeadline sets the underlying conn's deadline to now plus
+// idleTimeout, capped at hardDeadline so a steady trickle of activity
+// can't keep the connection alive indefinitely.
+func (c *DeadlineConn) applyDeadline() error {
+	deadline := time.Now().Add(c.idleTimeout)
+	if c.hardDeadline.Before(deadline) {
+		deadline = c.hardDeadline
+	}
+	return c.Conn.SetDeadline(deadline)
+}
+
+// DrainNotifier lets a long-lived hijacked connection (a websocket or
+// streaming proxy) learn when a graceful shutdown or reload has
+// started, so it can wind down instead of blocking the restart
+// forever. A directive creates one (typically shared across all its
+// connections) and calls Signal when the shutdown sequence begins;
+// each connection's handler selects on Done alongside its own reads
+// and writes. Actually triggering Signal from a real shutdown/reload
+// sequence, and enforcing a drain deadline once triggered, need the
+// process lifecycle infrastructure this tree doesn't have.
+type DrainNotifier struct {
+	once sync.Once
+	done chan struct{}
+}
+
+// NewDrainNotifier returns a DrainNotifier ready to use.
+func NewDrainNotifier() *DrainNotifier {
+	return &DrainNotifier{done: make(chan struct{})}
+}
+
+// Signal marks the notifier as draining. Safe to call more than once
+// or from multiple goroutines; only the first call has an effect.
+func (d *DrainNotifier) Signal() {
+	d.once.Do(func() { close(d.done) })
+}
+
+// Done returns a channel that's closed once Signal has been called,
+// for a connection handler's select alongside its reads and writes.
+func (d *DrainNotifier) Done() <-chan struct{} {
+	return d.done
+}
+
+// Draining reports whether Signal has been called, for a plain
+// ordinary (non-hijacked) request handler that just needs a
+// non-blocking check rather than a select on Done.
+func (d *DrainNotifier) Draining() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// AnnounceDraining sets a Connection: close header on the current
+// response if notifier is draining, so keep-alive clients start a new
+// connection for their next request instead of reusing one that's
+// about to be torn down, the per-request half of graceful shutdown. It
+// reports whether it set the header. Actually stopping the listener
+// from accepting new connections, waiting out the grace period for
+// in-flight requests to finish, and force-closing what's left
+// afterward need the process lifecycle infrastructure (and the
+// flag/directive wiring to configure the grace period) this tree
+// doesn't have.
+func (c Context) AnnounceDraining(notifier *DrainNotifier) bool {
+	if !notifier.Draining() {
+		return false
+	}
+	c.ResponseWriter.Header().Set("Connection", "close")
+	return true
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import "time"
+
+// sweepGate throttles a periodic map-eviction pass to at most once per
+// interval. RateLimiter, Banner, and KeepAliveLimiter each embed one
+// so their per-key state (one entry per client IP or similar) gets
+// pruned without walking the whole map on every single request. The
+// zero value is due immediately on its first check.
+type sweepGate struct {
+	last time.Time
+}
+
+// due reports whether interval has elapsed since the last time it
+// reported true, and if so, resets its clock to now.
+func (g *sweepGate) due(now time.Time, interval time.Duration) bool {
+	if !g.last.IsZero() && now.Sub(g.last) < interval {
+		return false
+	}
+	g.last = now
+	return true
+}
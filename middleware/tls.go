@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+)
+
+// TLS returns the connection's TLS state, or nil if the request didn't
+// arrive over TLS, so a template can check {{if .TLS}}{{.TLS.Version}}
+// ... or similar for connection details like the negotiated cipher
+// suite and protocol version.
+func (c Context) TLS() *tls.ConnectionState {
+	return c.Req.TLS
+}
+
+// ClientCertificate returns the verified leaf client certificate
+// presented on a mutual-TLS connection, or nil if the request didn't
+// arrive over TLS or presented none, so a template can render a
+// per-client page from {{with .ClientCertificate}}{{.Subject}}
+// {{.SerialNumber}}{{.DNSNames}}{{end}} without reaching into
+// TLS().PeerCertificates itself.
+func (c Context) ClientCertificate() *x509.Certificate {
+	state := c.TLS()
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// CertificateExpiringWithin reports whether cert's NotAfter falls
+// within window of now, the building block a certificate inventory or
+// monitoring script needs to page someone before a certificate
+// expires. Exposing that inventory (hostname, issuer, days remaining)
+// over an admin/metrics endpoint, and firing hooks on renewal
+// success/failure, both need lifecycle infrastructure — a certificate
+// store and a renewal loop — that this request-scoped middleware
+// package doesn't have; see RunAuthenticatedHookCommand's doc comment
+// for the same limitation on the hooks side.
+func CertificateExpiringWithin(cert *x509.Certificate, now time.Time, window time.Duration) bool {
+	return cert.NotAfter.Sub(now) <= window
+}
+
+// TLSServerName returns the SNI hostname the client requested during
+// the handshake, or "" if the request didn't arrive over TLS.
+func (c Context) TLSServerName() string {
+	state := c.TLS()
+	if state == nil {
+		return ""
+	}
+	return state.ServerName
+}
+
+// TLSVersionName returns a human-readable name for the negotiated TLS
+// version (e.g. "TLS 1.3"), or "" if the request didn't arrive over
+// TLS.
+func (c Context) TLSVersionName() string {
+	state := c.TLS()
+	if state == nil {
+		return ""
+	}
+	return tls.VersionName(state.Version)
+}
+
+// TLSCipherSuiteName returns the name of the negotiated cipher suite,
+// or "" if the request didn't arrive over TLS.
+func (c Context) TLSCipherSuiteName() string {
+	state := c.TLS()
+	if state == nil {
+		return ""
+	}
+	return tls.CipherSuiteName(state.CipherSuite)
+}
+
+// TLSResumed reports whether the connection resumed a previous TLS
+// session rather than performing a full handshake, for debugging
+// mysterious differences between a client's first and later requests.
+func (c Context) TLSResumed() bool {
+	state := c.TLS()
+	return state != nil && state.DidResume
+}
+
+// TLSClientCertSubject returns the presented client certificate's
+// subject as a string (e.g. "CN=client.example.com"), or "" if none
+// was presented, so a log line can record who authenticated without
+// reaching into ClientCertificate() itself.
+func (c Context) TLSClientCertSubject() string {
+	cert := c.ClientCertificate()
+	if cert == nil {
+		return ""
+	}
+	return cert.Subject.String()
+}
+
+// A counter of handshake failures by reason isn't implemented here: a
+// failed handshake never produces a request, so it never reaches a
+// Context, and this package has no metrics sink to record into even if
+// it did (see RunHookCommand's doc comment for the same gap). That
+// belongs to whatever terminates TLS ahead of this package.
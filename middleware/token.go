@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// SignToken returns the hex-encoded HMAC-SHA256 of value with secret,
+// for a template to embed as a CSRF token alongside the value it
+// guards (e.g. a form field or session ID).
+func (c Context) SignToken(value, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyToken reports whether token is the valid SignToken signature
+// of value under secret.
+func (c Context) VerifyToken(value, token, secret string) bool {
+	return hmac.Equal([]byte(c.SignToken(value, secret)), []byte(token))
+}
+
+// ErrInvalidJWT is returned by ParseJWT when tokenString isn't a
+// well-formed HS256 JWT, or its signature doesn't verify under secret.
+var ErrInvalidJWT = errors.New("middleware: invalid or unverifiable JWT")
+
+// ErrJWTExpired is returned by ParseJWT when the token's "exp" claim
+// is in the past, or its "nbf" claim is in the future.
+var ErrJWTExpired = errors.New("middleware: JWT is expired or not yet valid")
+
+// ParseJWT verifies an HS256 (HMAC-SHA256) JSON Web Token against
+// secret and returns its claims, for a `jwt` directive to authenticate
+// a bearer token and expose claims as placeholders without a
+// dependency outside the standard library. It checks the "exp" and
+// "nbf" claims if present, but doesn't fetch a JWKS URL or support
+// RSA/ECDSA-signed tokens: those need a JOSE library this package
+// doesn't otherwise depend on.
+func (c Context) ParseJWT(tokenString, secret string) (map[string]interface{}, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidJWT
+	}
+
+	header, err := decodeJWTSegment(parts[0])
+	if err != nil {
+		return nil, ErrInvalidJWT
+	}
+	var headerFields struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &headerFields); err != nil || headerFields.Alg != "HS256" {
+		return nil, ErrInvalidJWT
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidJWT
+	}
+
+	payload, err := decodeJWTSegment(parts[1])
+	if err != nil {
+		return nil, ErrInvalidJWT
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidJWT
+	}
+
+	now := c.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0)) {
+		return nil, ErrJWTExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrJWTExpired
+	}
+
+	return claims, nil
+}
+
+// decodeJWTSegment base64url-decodes a JWT header/payload segment,
+// which JWT specifies as unpadded.
+func decodeJWTSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// TraceHeader is the header TraceID reads and propagates by default.
+const TraceHeader = "X-Trace-Id"
+
+// TraceID returns the value of the request's TraceHeader, generating
+// and setting a new random one on both the request and response if it
+// isn't already present. This lets a trace ID picked by an edge proxy
+// flow through to logs and downstream requests (e.g. via HTTPInclude),
+// while still producing one for requests that arrive without it.
+func (c Context) TraceID() (string, error) {
+	if id := c.Req.Header.Get(TraceHeader); id != "" {
+		return id, nil
+	}
+
+	id, err := randomHexID(16)
+	if err != nil {
+		return "", err
+	}
+
+	c.Req.Header.Set(TraceHeader, id)
+	if c.ResponseWriter != nil {
+		c.ResponseWriter.Header().Set(TraceHeader, id)
+	}
+	return id, nil
+}
+
+// TraceParentHeader is the W3C Trace Context header TraceParent reads
+// and propagates.
+const TraceParentHeader = "traceparent"
+
+// TraceParent returns the request's W3C traceparent header
+// (https://www.w3.org/TR/trace-context/), generating and setting one
+// on both the request and response if it isn't already present, so a
+// child span ID is threaded through nested HTTPInclude calls the same
+// way TraceID threads X-Trace-Id. It doesn't record spans or export to
+// a collector: this package has no tracing backend client, so that
+// remains the job of whatever receives the propagated header.
+func (c Context) TraceParent() (string, error) {
+	if header := c.Req.Header.Get(TraceParentHeader); header != "" {
+		return header, nil
+	}
+
+	traceID, err := randomHexID(16)
+	if err != nil {
+		return "", err
+	}
+	spanID, err := randomHexID(8)
+	if err != nil {
+		return "", err
+	}
+
+	header := fmt.Sprintf("00-%s-%s-01", traceID, spanID)
+	c.Req.Header.Set(TraceParentHeader, header)
+	if c.ResponseWriter != nil {
+		c.ResponseWriter.Header().Set(TraceParentHeader, header)
+	}
+	return header, nil
+}
+
+// randomHexID returns a random hex-encoded ID of n random bytes.
+func randomHexID(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// UUID returns a random RFC 4122 version 4 UUID, for cache busting or a
+// form token in a template.
+func (c Context) UUID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	buf[6] = (buf[6] & 0x0f) | 0x40 // version 4
+	buf[8] = (buf[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16]), nil
+}
+
+// defaultRandomStringAlphabet is the alphabet RandomString uses when
+// alphabet is empty.
+const defaultRandomStringAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// RandomString returns a cryptographically random string of length
+// characters drawn from alphabet (or defaultRandomStringAlphabet if
+// alphabet is empty), for a CSRF token or cache-busting string in a
+// template.
+func (c Context) RandomString(length int, alphabet string) (string, error) {
+	if alphabet == "" {
+		alphabet = defaultRandomStringAlphabet
+	}
+
+	out := make([]byte, length)
+	for i := range out {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		out[i] = alphabet[n.Int64()]
+	}
+	return string(out), nil
+}
+
+// RandomInt returns a cryptographically random integer in [min, max),
+// for A/B bucket assignment in a template. It returns an error if
+// max <= min.
+func (c Context) RandomInt(min, max int) (int, error) {
+	if max <= min {
+		return 0, fmt.Errorf("middleware: RandomInt: max %d must be greater than min %d", max, min)
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import "net/http"
+
+// DeclareTrailer predeclares name as a trailer this handler will set
+// later via SetTrailer, by adding it to the response's Trailer header
+// before the body is written. It works with any
+// ResponseWriterWrapper-based writer in this package, since none of
+// them intercept Header() or otherwise treat Trailer specially, but it
+// must still be called before the first Write or WriteHeader call, the
+// same requirement an unwrapped http.ResponseWriter has.
+func (c Context) DeclareTrailer(name string) {
+	c.ResponseWriter.Header().Add("Trailer", name)
+}
+
+// SetTrailer sets a trailer value after the response body has been
+// written: for a name already predeclared with DeclareTrailer, or, if
+// it wasn't, using the http.TrailerPrefix convention so the standard
+// library still recognizes it as a trailer rather than a regular (and
+// by then too-late) header.
+func (c Context) SetTrailer(name, value string) {
+	header := c.ResponseWriter.Header()
+	for _, declared := range header["Trailer"] {
+		if declared == name {
+			header.Set(name, value)
+			return
+		}
+	}
+	header.Set(http.TrailerPrefix+name, value)
+}
+
+// CopyTrailers copies every trailer key/value in src (typically an
+// upstream *http.Response's Trailer, once its body has been fully
+// read) onto the current response as trailers, using
+// http.TrailerPrefix since they weren't predeclared, so gRPC and other
+// trailer-carrying streaming responses (e.g. a Grpc-Status trailer)
+// survive a proxy hop through this package's gzip/log/header writers
+// instead of being dropped when the body finishes.
+func (c Context) CopyTrailers(src http.Header) {
+	header := c.ResponseWriter.Header()
+	for name, values := range src {
+		for _, value := range values {
+			header.Add(http.TrailerPrefix+name, value)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// ErrUploadRootNotWritable is returned by SaveUploadedFile when c.Root
+// isn't an http.Dir, since Context otherwise only knows Root as a
+// read-only http.FileSystem and has nowhere to write the upload to.
+var ErrUploadRootNotWritable = errors.New("middleware: uploads require an http.Dir root")
+
+// SaveUploadedFile reads the multipart file field named fieldName from
+// the request and writes it to destName, relative to the site root.
+// destName is clamped to the root the same way http.Dir clamps read
+// paths, so a destName like "../../etc/passwd" is confined under the
+// root rather than escaping it. It returns the number of bytes written.
+func (c Context) SaveUploadedFile(fieldName, destName string) (int64, error) {
+	dir, ok := c.Root.(http.Dir)
+	if !ok {
+		return 0, ErrUploadRootNotWritable
+	}
+
+	file, _, err := c.Req.FormFile(fieldName)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	destPath := joinUnderRoot(dir, destName)
+
+	if err := os.MkdirAll(filepath.Dir(destPath), c.dirMode()); err != nil {
+		return 0, err
+	}
+
+	out, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, c.fileMode())
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	return io.Copy(out, file)
+}
+
+// fileMode returns c.FileMode, or 0644 if it's unset.
+func (c Context) fileMode() os.FileMode {
+	if c.FileMode == 0 {
+		return 0644
+	}
+	return c.FileMode
+}
+
+// dirMode returns c.DirMode, or 0755 if it's unset.
+func (c Context) dirMode() os.FileMode {
+	if c.DirMode == 0 {
+		return 0755
+	}
+	return c.DirMode
+}
+
+// unsafeFilenameChars matches everything but letters, digits, dots,
+// dashes and underscores, for SanitizeFilename.
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9.\-_]`)
+
+// SanitizeFilename strips directory components from name and replaces
+// any character other than a letter, digit, dot, dash or underscore
+// with "_", so a user-supplied upload filename can't be used to
+// traverse or hide the destination path.
+func SanitizeFilename(name string) string {
+	name = filepath.Base(filepath.FromSlash(name))
+	return unsafeFilenameChars.ReplaceAllString(name, "_")
+}
+
+// SaveUploadedFileRandom behaves like SaveUploadedFile, except the
+// destination filename is a random 32-character hex string with ext
+// appended (e.g. ".png"), rather than one chosen by the caller. It
+// returns the generated filename along with the number of bytes
+// written, so a directive can hand the generated name back to the
+// client.
+func (c Context) SaveUploadedFileRandom(fieldName, destDir, ext string) (string, int64, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", 0, err
+	}
+	name := hex.EncodeToString(random) + ext
+
+	written, err := c.SaveUploadedFile(fieldName, path.Join(destDir, name))
+	if err != nil {
+		return "", 0, err
+	}
+	return name, written, nil
+}
+
+// ErrChunkedUploadAlreadyComplete is returned by SaveUploadChunk when
+// all of an upload's chunks have already been received and assembled.
+var ErrChunkedUploadAlreadyComplete = errors.New("middleware: chunked upload already complete")
+
+// ChunkedUploadTracker records which chunk indexes have arrived for
+// each in-progress chunked upload, so SaveUploadChunk can tell when
+// the last one lands and it's time to assemble the final file. The
+// zero value is ready to use.
+type ChunkedUploadTracker struct {
+	mu        sync.Mutex
+	received  map[string]map[int]bool
+	completed map[string]bool
+}
+
+// chunksFor returns the set of chunk indexes seen so far for uploadID,
+// creating it if necessary.
+func (t *ChunkedUploadTracker) chunksFor(uploadID string) map[int]bool {
+	if t.received == nil {
+		t.received = make(map[string]map[int]bool)
+	}
+	chunks, ok := t.received[uploadID]
+	if !ok {
+		chunks = make(map[int]bool)
+		t.received[uploadID] = chunks
+	}
+	return chunks
+}
+
+// SaveUploadChunk saves chunk chunkIndex (0-based) of an out of
+// totalChunks for uploadID, read from the multipart file field named
+// fieldName, to a temporary ".part" file alongside destName. Once
+// every chunk has arrived, it concatenates them in order into
+// destName, removes the part files, and returns complete=true, so a
+// resumable/tus-style upload can survive a dropped connection between
+// chunks without holding the whole file in memory at once.
+func (c Context) SaveUploadChunk(tracker *ChunkedUploadTracker, uploadID string, chunkIndex, totalChunks int, fieldName, destName string) (complete bool, err error) {
+	tracker.mu.Lock()
+	if tracker.completed[uploadID] {
+		tracker.mu.Unlock()
+		return false, ErrChunkedUploadAlreadyComplete
+	}
+	chunks := tracker.chunksFor(uploadID)
+	tracker.mu.Unlock()
+
+	partName := fmt.Sprintf("%s.part%d", destName, chunkIndex)
+	if _, err := c.SaveUploadedFile(fieldName, partName); err != nil {
+		return false, err
+	}
+
+	tracker.mu.Lock()
+	chunks[chunkIndex] = true
+	complete = len(chunks) >= totalChunks
+	if complete {
+		delete(tracker.received, uploadID)
+		if tracker.completed == nil {
+			tracker.completed = make(map[string]bool)
+		}
+		tracker.completed[uploadID] = true
+	}
+	tracker.mu.Unlock()
+
+	if !complete {
+		return false, nil
+	}
+
+	if err := c.assembleUploadChunks(destName, totalChunks); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// assembleUploadChunks concatenates the totalChunks ".part" files
+// written by SaveUploadChunk, in order, into destName, then removes
+// them.
+func (c Context) assembleUploadChunks(destName string, totalChunks int) error {
+	dir, ok := c.Root.(http.Dir)
+	if !ok {
+		return ErrUploadRootNotWritable
+	}
+	destPath := joinUnderRoot(dir, destName)
+
+	out, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, c.fileMode())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	for i := 0; i < totalChunks; i++ {
+		partPath := fmt.Sprintf("%s.part%d", destPath, i)
+		if err := appendFile(out, partPath); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < totalChunks; i++ {
+		os.Remove(fmt.Sprintf("%s.part%d", destPath, i))
+	}
+	return nil
+}
+
+// appendFile copies the contents of the file at path onto the end of
+// out.
+func appendFile(out *os.File, path string) error {
+	part, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	_, err = io.Copy(out, part)
+	return err
+}
+
+// ErrDestinationExists is returned by RenamePath when newName already
+// exists, so an opt-in browse file-manager doesn't silently clobber an
+// existing file or directory.
+var ErrDestinationExists = errors.New("middleware: destination already exists")
+
+// MakeDir creates the directory named name, relative to the site root,
+// along with any missing parents, for a read-write browse directive's
+// "new folder" action. It's a no-op if name already exists as a
+// directory.
+func (c Context) MakeDir(name string) error {
+	dir, ok := c.Root.(http.Dir)
+	if !ok {
+		return ErrUploadRootNotWritable
+	}
+	return os.MkdirAll(joinUnderRoot(dir, name), c.dirMode())
+}
+
+// DeletePath removes the file or directory (recursively) named name,
+// relative to the site root, for a read-write browse directive's
+// "delete" action.
+func (c Context) DeletePath(name string) error {
+	dir, ok := c.Root.(http.Dir)
+	if !ok {
+		return ErrUploadRootNotWritable
+	}
+	return os.RemoveAll(joinUnderRoot(dir, name))
+}
+
+// RenamePath moves the file or directory named oldName to newName,
+// both relative to the site root, for a read-write browse directive's
+// "rename" action. It returns ErrDestinationExists rather than
+// overwriting an existing file or directory at newName.
+func (c Context) RenamePath(oldName, newName string) error {
+	dir, ok := c.Root.(http.Dir)
+	if !ok {
+		return ErrUploadRootNotWritable
+	}
+
+	oldPath := joinUnderRoot(dir, oldName)
+	newPath := joinUnderRoot(dir, newName)
+
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("%w: %s", ErrDestinationExists, newName)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), c.dirMode()); err != nil {
+		return err
+	}
+
+	return os.Rename(oldPath, newPath)
+}
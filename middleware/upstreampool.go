@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Upstream is one backend address in a pool passed to SelectUpstream.
+// Backup upstreams only receive traffic once every non-backup
+// upstream is unavailable.
+type Upstream struct {
+	Address string
+	Backup  bool
+}
+
+// ErrUpstreamPoolDown is returned by SelectUpstream when every
+// upstream in the pool, primary and backup alike, is unavailable.
+var ErrUpstreamPoolDown = errors.New("middleware: no upstream in the pool is available")
+
+// SelectUpstream picks the first available upstream in pool: it
+// checks non-backup upstreams in order first, then backup upstreams in
+// order, so a backup is only chosen once every primary is unhealthy.
+// Availability is decided the same way CircuitAllowed does, via
+// breaker.Allow keyed by each upstream's Address; a directive reports
+// the outcome afterward with breaker.RecordSuccess/RecordFailure the
+// same as it would for a single upstream. If nothing in pool is
+// available, it returns ErrUpstreamPoolDown so the caller can serve a
+// fallback response (e.g. via RespondFixed or ServeFallback) instead
+// of proxying.
+func (c Context) SelectUpstream(pool []Upstream, breaker *CircuitBreaker, cooldown time.Duration) (string, error) {
+	for _, backup := range []bool{false, true} {
+		for _, u := range pool {
+			if u.Backup != backup {
+				continue
+			}
+			if breaker.Allow(u.Address, c.Now(), cooldown) {
+				return u.Address, nil
+			}
+		}
+	}
+	return "", ErrUpstreamPoolDown
+}
+
+// ResolveUpstreamFromHeader reads headerName off the current request
+// (as a `map` directive keyed on a JWT claim, or similar, would set it
+// before proxy runs) and validates it against c.EgressPolicy, the same
+// allowlist/denylist HTTPInclude and Webhook enforce, for a `proxy`
+// directive computing its target per request (e.g. full Caddy's own
+// `proxy / http://{>X-Tenant-Backend}` syntax) instead of a fixed
+// address. Unlike a hardcoded target, whatever set headerName could
+// otherwise redirect the request anywhere, so this validation step
+// isn't optional the way it is for HTTPInclude/Webhook's fixed,
+// developer-supplied URLs.
+func (c Context) ResolveUpstreamFromHeader(headerName string) (string, error) {
+	target := c.Header(headerName)
+	if target == "" {
+		return "", fmt.Errorf("middleware: header %q has no upstream target", headerName)
+	}
+	if err := checkEgress(c.EgressPolicy, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
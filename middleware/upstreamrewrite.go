@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/url"
+	"strings"
+)
+
+// StripPathPrefix rewrites the request so prefix is removed from its
+// path before forwarding to a proxy or fastcgi backend that doesn't
+// expect it, e.g. StripPathPrefix("/api") turns "/api/users" into
+// "/users" ("/" if nothing is left). Like Rewrite, it preserves the
+// original path via the originalURIHeader mechanism, so OriginalURI
+// still reports "/api/users" for logging or RewriteUpstreamLocation.
+// If the current path doesn't have prefix, the request is left
+// unchanged and both return values are zero.
+func (c Context) StripPathPrefix(prefix string) (string, error) {
+	if prefix == "" || !strings.HasPrefix(c.Req.URL.Path, prefix) {
+		return "", nil
+	}
+
+	stripped := strings.TrimPrefix(c.Req.URL.Path, prefix)
+	if stripped == "" {
+		stripped = "/"
+	}
+	return c.Rewrite(withRawQuery(stripped, c.Req.URL.RawQuery))
+}
+
+// ReplaceURIForUpstream rewrites the request path by replacing the
+// first occurrence of old with replacement, for a `uri replace`
+// option needing more than a plain prefix strip (e.g. replacing
+// "/old-api" with "/v2/api" anywhere in the path) before forwarding to
+// a proxy or fastcgi backend. If the current path doesn't contain
+// old, the request is left unchanged and both return values are zero.
+func (c Context) ReplaceURIForUpstream(old, replacement string) (string, error) {
+	if old == "" || !strings.Contains(c.Req.URL.Path, old) {
+		return "", nil
+	}
+
+	target := strings.Replace(c.Req.URL.Path, old, replacement, 1)
+	return c.Rewrite(withRawQuery(target, c.Req.URL.RawQuery))
+}
+
+// withRawQuery appends rawQuery to path as a "?"-separated query
+// string, if rawQuery is non-empty.
+func withRawQuery(path, rawQuery string) string {
+	if rawQuery == "" {
+		return path
+	}
+	return path + "?" + rawQuery
+}
+
+// RewriteUpstreamLocation rewrites a backend's Location response
+// header, if present, so a path under from becomes the same path
+// under to (e.g. RewriteUpstreamLocation("/users", "/api/users") to
+// undo a StripPathPrefix("/api")), so a redirect a backend issues
+// based on its own view of the URL space still points somewhere valid
+// from the client's point of view. It must be called before the
+// response status is written, the same constraint RewriteResponseCookies
+// has for Set-Cookie. If Location isn't set, or doesn't have from as a
+// path prefix, it's left alone.
+func (c Context) RewriteUpstreamLocation(from, to string) {
+	location := c.ResponseWriter.Header().Get("Location")
+	if location == "" {
+		return
+	}
+
+	u, err := url.Parse(location)
+	if err != nil || !strings.HasPrefix(u.Path, from) {
+		return
+	}
+
+	u.Path = to + strings.TrimPrefix(u.Path, from)
+	c.ResponseWriter.Header().Set("Location", u.String())
+}
+
+// RewriteUpstreamHost rewrites the Location and Refresh headers on the
+// current response, if either references internalHost (an upstream's
+// own host:port, as it might set in a redirect issued without knowing
+// about the public-facing site), replacing it with publicHost so the
+// client lands back on the public address instead of the backend's
+// internal one. Like RewriteUpstreamLocation, it must be called before
+// the response status is written. Rewriting links embedded in an HTML
+// body is RewriteBody's job, given a BodyReplacement for internalHost.
+func (c Context) RewriteUpstreamHost(internalHost, publicHost string) {
+	header := c.ResponseWriter.Header()
+
+	if location := header.Get("Location"); location != "" {
+		header.Set("Location", replaceHostInURL(location, internalHost, publicHost))
+	}
+	if refresh := header.Get("Refresh"); refresh != "" {
+		header.Set("Refresh", strings.Replace(refresh, internalHost, publicHost, 1))
+	}
+}
+
+// replaceHostInURL replaces internalHost with publicHost in rawURL if
+// rawURL parses and its host is exactly internalHost; otherwise
+// rawURL is returned unchanged, so a path-only Location (already
+// relative to the public site) isn't corrupted by a coincidental
+// substring match.
+func replaceHostInURL(rawURL, internalHost, publicHost string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host != internalHost {
+		return rawURL
+	}
+	u.Host = publicHost
+	return u.String()
+}
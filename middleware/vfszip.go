@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ZipFileSystem serves content out of a zip archive instead of a
+// directory on disk. It implements http.FileSystem, so it drops
+// straight into Context.Root or IncludeRoot alongside http.Dir,
+// letting a site be deployed as a single archive file. Regular file
+// contents are read fully into memory on Open, since zip.File only
+// exposes a non-seekable io.ReadCloser and http.File requires
+// io.Seeker; this is a reasonable trade-off for the kind of small,
+// mostly-static content archives are used for here.
+type ZipFileSystem struct {
+	reader *zip.Reader
+}
+
+// NewZipFileSystem opens a ZipFileSystem over ra, which must hold size
+// bytes of valid zip archive data (for example an *os.File or a
+// bytes.Reader over an archive read into memory).
+func NewZipFileSystem(ra io.ReaderAt, size int64) (*ZipFileSystem, error) {
+	reader, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, err
+	}
+	return &ZipFileSystem{reader: reader}, nil
+}
+
+// Open implements http.FileSystem, resolving name against the
+// archive's entries the way http.Dir resolves it against a directory.
+func (fs *ZipFileSystem) Open(name string) (http.File, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	if name == "" {
+		return &zipDir{fs: fs, name: ""}, nil
+	}
+
+	for _, f := range fs.reader.File {
+		if strings.TrimSuffix(f.Name, "/") != name {
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			return &zipDir{fs: fs, name: name}, nil
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		data, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return nil, err
+		}
+		return &zipFile{Reader: bytes.NewReader(data), info: f.FileInfo()}, nil
+	}
+
+	if fs.hasDirPrefix(name) {
+		return &zipDir{fs: fs, name: name}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// hasDirPrefix reports whether name is a directory implied by some
+// entry's path, for archives (common with zip -r) that don't carry
+// explicit directory entries.
+func (fs *ZipFileSystem) hasDirPrefix(name string) bool {
+	prefix := name + "/"
+	for _, f := range fs.reader.File {
+		if strings.HasPrefix(f.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// zipFile is the http.File returned for a regular archive entry.
+type zipFile struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+func (f *zipFile) Close() error { return nil }
+
+func (f *zipFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.info.Name(), Err: os.ErrInvalid}
+}
+
+func (f *zipFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+// zipDir is the http.File returned for an archive directory (whether
+// or not the archive carries an explicit entry for it).
+type zipDir struct {
+	fs   *ZipFileSystem
+	name string
+}
+
+func (d *zipDir) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (d *zipDir) Seek(offset int64, whence int) (int64, error) { return 0, nil }
+
+func (d *zipDir) Close() error { return nil }
+
+func (d *zipDir) Stat() (os.FileInfo, error) { return zipDirInfo(path.Base(d.name)), nil }
+
+func (d *zipDir) Readdir(count int) ([]os.FileInfo, error) {
+	prefix := d.name
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	seen := make(map[string]bool)
+	var infos []os.FileInfo
+	for _, f := range d.fs.reader.File {
+		if !strings.HasPrefix(f.Name, prefix) {
+			continue
+		}
+		rest := strings.TrimSuffix(strings.TrimPrefix(f.Name, prefix), "/")
+		if rest == "" || strings.Contains(rest, "/") {
+			continue
+		}
+		if seen[rest] {
+			continue
+		}
+		seen[rest] = true
+
+		if strings.HasSuffix(f.Name, "/") {
+			infos = append(infos, zipDirInfo(rest))
+		} else {
+			infos = append(infos, f.FileInfo())
+		}
+	}
+	return infos, nil
+}
+
+// zipDirInfo is a minimal os.FileInfo for a zip directory entry,
+// synthesized when the archive has no explicit entry for it.
+type zipDirInfo string
+
+func (i zipDirInfo) Name() string       { return string(i) }
+func (i zipDirInfo) Size() int64        { return 0 }
+func (i zipDirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (i zipDirInfo) ModTime() time.Time { return time.Time{} }
+func (i zipDirInfo) IsDir() bool        { return true }
+func (i zipDirInfo) Sys() interface{}   { return nil }
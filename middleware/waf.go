@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// InspectionRule is one rule of a WAF-lite request inspection chain: it
+// matches requests via Match and reports Action ("allow", "deny", or
+// "log") to take when it does.
+type InspectionRule struct {
+	Match  Matcher
+	Action string
+}
+
+// EvaluateRules returns the Action of the first rule in rules that
+// matches c, or "allow" if none do, for a directive to decide whether
+// to block, log, or pass through a request against a loaded rule set.
+func EvaluateRules(c Context, rules []InspectionRule) string {
+	for _, rule := range rules {
+		if rule.Match(c) {
+			return rule.Action
+		}
+	}
+	return "allow"
+}
+
+// RegexMatcher returns a Matcher that matches when pattern matches the
+// string target extracts from the request, e.g. PathTarget or
+// QueryTarget(name), for an InspectionRule that flags common injection
+// probes (SQL/script fragments, path traversal, ...) in a request's
+// path, query, or header values. A pattern that fails to compile never
+// matches, rather than making the whole rule error out.
+func RegexMatcher(pattern string, target func(c Context) string) Matcher {
+	re, err := compiledRegexp(pattern)
+	return func(c Context) bool {
+		if err != nil {
+			return false
+		}
+		return re.MatchString(target(c))
+	}
+}
+
+// PathTarget is a RegexMatcher target that inspects the request path.
+func PathTarget(c Context) string {
+	return c.Req.URL.Path
+}
+
+// QueryTarget returns a RegexMatcher target that inspects the query
+// string parameter name.
+func QueryTarget(name string) func(c Context) string {
+	return func(c Context) string { return c.Query(name) }
+}
+
+// HeaderTarget returns a RegexMatcher target that inspects the request
+// header name.
+func HeaderTarget(name string) func(c Context) string {
+	return func(c Context) string { return c.Header(name) }
+}
+
+// BodyTarget returns a RegexMatcher target that inspects up to
+// maxBytes of the request body, for flagging an injection payload in a
+// POST/PUT body rather than just the path, query, or headers. It
+// restores whatever it read onto c.Req.Body afterward, so a later
+// handler still sees the full body.
+func BodyTarget(maxBytes int64) func(c Context) string {
+	return func(c Context) string {
+		if c.Req.Body == nil {
+			return ""
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(c.Req.Body, maxBytes))
+		if err != nil {
+			return ""
+		}
+		c.Req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), c.Req.Body))
+		return string(body)
+	}
+}
+
+// SizeMatcher returns a Matcher that matches when the request's
+// Content-Length exceeds maxBytes, for an InspectionRule that blocks
+// oversized payloads regardless of their content.
+func SizeMatcher(maxBytes int64) Matcher {
+	return func(c Context) bool { return c.Req.ContentLength > maxBytes }
+}
@@ -0,0 +1,19 @@
+package middleware
+
+// WarmPaths pre-renders each of paths by calling Include, priming the
+// OS file cache and surfacing template errors before real traffic
+// arrives. It doesn't hook into a startup lifecycle: this tree has no
+// listener/server-core layer to run it before listeners accept
+// connections, so a caller wanting warmup-before-serving must invoke
+// it itself at the point it starts listening. It returns the first
+// error encountered, continuing to render the remaining paths first so
+// a single bad template doesn't skip warming the rest.
+func (c Context) WarmPaths(paths []string) error {
+	var firstErr error
+	for _, path := range paths {
+		if _, err := c.Include(path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchedList holds the non-empty, non-comment ("#"-prefixed) lines of
+// a text file, refreshing them from disk whenever the file's
+// modification time changes. It backs directives like ipfilter that
+// want their data updated by editing a file rather than the
+// Caddyfile, without a restart. The zero value is ready to use once
+// Path is set.
+type WatchedList struct {
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	lines   []string
+}
+
+// Entries returns the list's current lines, reloading Path first if
+// its modification time has changed since the last call. A read error
+// (including the file not existing) leaves the previously loaded
+// lines in place and is otherwise ignored, so a transient issue (e.g.
+// an editor briefly removing the file while saving) doesn't blank out
+// an active list.
+func (w *WatchedList) Entries() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	info, err := os.Stat(w.Path)
+	if err != nil {
+		return w.lines
+	}
+	if w.lines != nil && !info.ModTime().After(w.modTime) {
+		return w.lines
+	}
+
+	file, err := os.Open(w.Path)
+	if err != nil {
+		return w.lines
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	w.lines = lines
+	w.modTime = info.ModTime()
+	return w.lines
+}
+
+// IPFilterFromWatchedLists builds an IPFilter from the current
+// contents of allow and deny (either may be nil for an empty list),
+// so a directive backed by hot-reloaded files can pass the result
+// straight to IPAllowed on every request without managing the reload
+// itself.
+func IPFilterFromWatchedLists(allow, deny *WatchedList) IPFilter {
+	var f IPFilter
+	if allow != nil {
+		f.Allow = allow.Entries()
+	}
+	if deny != nil {
+		f.Deny = deny.Entries()
+	}
+	return f
+}
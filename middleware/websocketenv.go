@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WebsocketEnv builds an exec.Cmd.Env-shaped slice of "KEY=VALUE"
+// entries for a `websocket` (exec-backed) directive's child process,
+// mirroring the HTTP_* environment variable convention net/http/cgi
+// uses for ServeCGI: each header becomes HTTP_<NAME> (dashes turned
+// to underscores, uppercased) and each query parameter becomes
+// QUERY_<NAME>, so the process can read connection metadata the way a
+// CGI script reads request metadata. Choosing text vs binary framing
+// and line-buffered vs raw stdin/stdout bridging need a working
+// websocket implementation (handshake plus RFC 6455 frame
+// encode/decode) to apply to, which this tree doesn't have; limiting
+// concurrent connections per command can already be done with the
+// existing ConcurrencyLimiter, the same way ServeCGI caps script
+// executions.
+func WebsocketEnv(header http.Header, query url.Values) []string {
+	var env []string
+	for name, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		key := "HTTP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		env = append(env, key+"="+values[0])
+	}
+	for name, values := range query {
+		if len(values) == 0 {
+			continue
+		}
+		key := "QUERY_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		env = append(env, key+"="+values[0])
+	}
+	return env
+}